@@ -0,0 +1,252 @@
+package baudit
+
+import (
+	"errors"
+	"fmt"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/lbtool/pkg/jsonpb"
+	"github.com/oldbai555/lbtool/pkg/lberr"
+	"github.com/oldbai555/micro/uctx"
+	"google.golang.org/protobuf/proto"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMaxBodyBytes = 1024
+
+// defaultSampleRate 成功的调用默认的采样率
+const defaultSampleRate = 0.01
+
+// 安全相关的事件, 不受采样与 Disable 的影响
+const (
+	EventPermissionDenied = "permission_denied" // 鉴权拒绝
+	EventTenantBypass     = "tenant_bypass"     // gormx 跳过租户隔离, Path 为表名
+)
+
+// Record 一条访问审计日志
+type Record struct {
+	Time      string `json:"time"`
+	TraceId   string `json:"trace_id"`
+	Path      string `json:"path"`
+	Event     string `json:"event,omitempty"`
+	Sid       string `json:"sid,omitempty"`
+	DeviceId  string `json:"device_id,omitempty"`
+	AuthType  string `json:"auth_type,omitempty"`
+	Principal string `json:"principal,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+	ErrCode   int32  `json:"errcode"`
+	ErrMsg    string `json:"errmsg,omitempty"`
+	Req       string `json:"req,omitempty"`
+	Rsp       string `json:"rsp,omitempty"`
+}
+
+// IPrincipal ExtInfo 实现该接口后, 审计日志会记录调用方
+type IPrincipal interface {
+	AuditPrincipal() string
+}
+
+// CmdOption 命令级别的审计配置, 零值表示使用 Auditor 的配置
+type CmdOption struct {
+	Disable      bool
+	SampleRate   float64  // 成功调用的采样率 (0, 1], 出错的请求总会记录
+	MaxBodyBytes int      // 请求与响应的最大记录长度
+	RedactFields []string // 额外脱敏的字段名
+	SkipBody     bool     // 不记录请求与响应
+}
+
+// Auditor 审计日志, 成功的调用默认按 1% 采样, 出错的调用与安全事件总会记录
+// 未配置 WithSink 时输出到 LogSink 且不记录请求与响应, 避免业务日志被请求体淹没
+type Auditor struct {
+	sink         Sink
+	sampleRate   float64
+	maxBodyBytes int
+	fieldSet     map[string]bool
+
+	randLock sync.Mutex
+	rand     *rand.Rand
+}
+
+type Option func(*Auditor)
+
+// WithSink 审计日志的输出, 建议与业务日志分开, 如 NewWriterSink 到单独的文件, 配置后才记录请求与响应
+func WithSink(sink Sink) Option {
+	return func(a *Auditor) {
+		a.sink = sink
+	}
+}
+
+// WithSampleRate 成功调用的采样率, 为 0 时只记录出错的调用与安全事件
+func WithSampleRate(rate float64) Option {
+	return func(a *Auditor) {
+		a.sampleRate = rate
+	}
+}
+
+func WithMaxBodyBytes(n int) Option {
+	return func(a *Auditor) {
+		a.maxBodyBytes = n
+	}
+}
+
+// WithRedactFields 追加脱敏的字段名
+func WithRedactFields(fields ...string) Option {
+	return func(a *Auditor) {
+		for _, field := range fields {
+			a.fieldSet[strings.ToLower(field)] = true
+		}
+	}
+}
+
+func NewAuditor(opts ...Option) *Auditor {
+	a := &Auditor{
+		sampleRate:   defaultSampleRate,
+		maxBodyBytes: defaultMaxBodyBytes,
+		fieldSet:     map[string]bool{},
+		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, field := range defaultRedactFields {
+		a.fieldSet[field] = true
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+var defaultAuditor = NewAuditor()
+
+func Default() *Auditor {
+	return defaultAuditor
+}
+
+// SetDefault 替换默认的 Auditor, 需在服务启动前调用
+func SetDefault(a *Auditor) {
+	defaultAuditor = a
+}
+
+func (a *Auditor) sampled(rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	a.randLock.Lock()
+	defer a.randLock.Unlock()
+	return a.rand.Float64() < rate
+}
+
+func (a *Auditor) encode(msg proto.Message, fieldSet map[string]bool, max int) string {
+	if msg == nil {
+		return ""
+	}
+	s, err := jsonpb.MarshalToString(Redact(msg, fieldSet))
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return ""
+	}
+	return truncate(s, max)
+}
+
+// Entry 一次调用的审计, 由 Start 开始计时
+type Entry struct {
+	a     *Auditor
+	nCtx  uctx.IUCtx
+	path  string
+	start time.Time
+	event string
+}
+
+// Start 使用默认的 Auditor 开始一次审计
+func Start(nCtx uctx.IUCtx, path string) *Entry {
+	return defaultAuditor.Start(nCtx, path)
+}
+
+func (a *Auditor) Start(nCtx uctx.IUCtx, path string) *Entry {
+	return &Entry{a: a, nCtx: nCtx, path: path, start: time.Now()}
+}
+
+// SetEvent 标记安全相关的事件, 如 EventPermissionDenied, 标记后总会记录
+func (e *Entry) SetEvent(event string) {
+	e.event = event
+}
+
+// End 记录结果, 未命中采样且没有出错时不输出
+func (e *Entry) End(opt *CmdOption, req, rsp proto.Message, err error) {
+	a := e.a
+	if opt == nil {
+		opt = &CmdOption{}
+	}
+	if opt.Disable && e.event == "" {
+		return
+	}
+	rate := a.sampleRate
+	if opt.SampleRate > 0 {
+		rate = opt.SampleRate
+	}
+	if err == nil && e.event == "" && !a.sampled(rate) {
+		return
+	}
+
+	rec := &Record{
+		Time:      e.start.Format(time.RFC3339Nano),
+		TraceId:   e.nCtx.TraceId(),
+		Path:      e.path,
+		Event:     e.event,
+		Sid:       e.nCtx.Sid(),
+		DeviceId:  e.nCtx.DeviceId(),
+		AuthType:  e.nCtx.AuthType(),
+		Principal: principalOf(e.nCtx.ExtInfo()),
+		LatencyMs: time.Since(e.start).Milliseconds(),
+	}
+	// sid 属于凭证, 只保留前缀
+	if len(rec.Sid) > 6 {
+		rec.Sid = rec.Sid[:6] + redactedMask
+	}
+	if err != nil {
+		var lbErr *lberr.Error
+		if errors.As(err, &lbErr) {
+			rec.ErrCode, rec.ErrMsg = lbErr.Code(), lbErr.Message()
+		} else {
+			rec.ErrCode, rec.ErrMsg = lberr.GetErrCode(err), err.Error()
+		}
+	}
+
+	// 关闭了审计的命令只记录事件本身, 未配置独立的输出时不记录请求与响应
+	sink := a.sink
+	if sink == nil {
+		sink = logSink
+	} else if !opt.SkipBody && !opt.Disable {
+		fieldSet := a.fieldSet
+		if len(opt.RedactFields) > 0 {
+			fieldSet = make(map[string]bool, len(a.fieldSet)+len(opt.RedactFields))
+			for k := range a.fieldSet {
+				fieldSet[k] = true
+			}
+			for _, field := range opt.RedactFields {
+				fieldSet[strings.ToLower(field)] = true
+			}
+		}
+		max := a.maxBodyBytes
+		if opt.MaxBodyBytes > 0 {
+			max = opt.MaxBodyBytes
+		}
+		rec.Req = a.encode(req, fieldSet, max)
+		rec.Rsp = a.encode(rsp, fieldSet, max)
+	}
+	sink.Write(rec)
+}
+
+func principalOf(extInfo interface{}) string {
+	switch p := extInfo.(type) {
+	case nil:
+		return ""
+	case IPrincipal:
+		return p.AuditPrincipal()
+	case fmt.Stringer:
+		return p.String()
+	}
+	return ""
+}
@@ -0,0 +1,132 @@
+package baudit
+
+import (
+	"github.com/oldbai555/micro/bconst"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+	"unicode/utf8"
+)
+
+const redactedMask = "***"
+
+const truncatedSuffix = "...(truncated)"
+
+// 默认脱敏的字段名
+var defaultRedactFields = []string{
+	"password", "passwd", "secret", "token", "access_token", "refresh_token", "sid",
+}
+
+// Redact 返回脱敏后的副本, 不修改原消息, 没有需要脱敏的字段时直接返回原消息
+// 字段名命中 fieldSet, 或字段上声明了 lb.api.sensitive = true 时脱敏
+// 字符串字段替换为 ***, 其余类型直接清空
+func Redact(msg proto.Message, fieldSet map[string]bool) proto.Message {
+	if msg == nil {
+		return nil
+	}
+	if !hasSensitive(msg.ProtoReflect(), fieldSet) {
+		return msg
+	}
+	clone := proto.Clone(msg)
+	redactMsg(clone.ProtoReflect(), fieldSet)
+	return clone
+}
+
+func redactMsg(m protoreflect.Message, fieldSet map[string]bool) {
+	var sensitiveList []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if isSensitive(fd, fieldSet) {
+			sensitiveList = append(sensitiveList, fd)
+			return true
+		}
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() != nil {
+				v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+					redactMsg(mv.Message(), fieldSet)
+					return true
+				})
+			}
+		case fd.IsList():
+			if fd.Message() != nil {
+				list := v.List()
+				for i := 0; i < list.Len(); i++ {
+					redactMsg(list.Get(i).Message(), fieldSet)
+				}
+			}
+		case fd.Message() != nil:
+			redactMsg(v.Message(), fieldSet)
+		}
+		return true
+	})
+
+	for _, fd := range sensitiveList {
+		if fd.Kind() == protoreflect.StringKind && !fd.IsList() && !fd.IsMap() {
+			m.Set(fd, protoreflect.ValueOfString(redactedMask))
+			continue
+		}
+		m.Clear(fd)
+	}
+}
+
+// hasSensitive 消息中是否有需要脱敏的字段, 与 redactMsg 的遍历一致
+func hasSensitive(m protoreflect.Message, fieldSet map[string]bool) bool {
+	var found bool
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if isSensitive(fd, fieldSet) {
+			found = true
+			return false
+		}
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() != nil {
+				v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+					found = hasSensitive(mv.Message(), fieldSet)
+					return !found
+				})
+			}
+		case fd.IsList():
+			if fd.Message() != nil {
+				list := v.List()
+				for i := 0; i < list.Len() && !found; i++ {
+					found = hasSensitive(list.Get(i).Message(), fieldSet)
+				}
+			}
+		case fd.Message() != nil:
+			found = hasSensitive(v.Message(), fieldSet)
+		}
+		return !found
+	})
+	return found
+}
+
+func isSensitive(fd protoreflect.FieldDescriptor, fieldSet map[string]bool) bool {
+	if fieldSet[strings.ToLower(string(fd.Name()))] {
+		return true
+	}
+	opts := fd.Options()
+	if opts == nil {
+		return false
+	}
+	var sensitive bool
+	opts.ProtoReflect().Range(func(ext protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if ext.IsExtension() && string(ext.FullName()) == bconst.ProtoOptionSensitive && ext.Kind() == protoreflect.BoolKind {
+			sensitive = v.Bool()
+			return false
+		}
+		return true
+	})
+	return sensitive
+}
+
+// truncate 按字节截断, 不截断半个 utf8 字符
+func truncate(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	s = s[:max]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s + truncatedSuffix
+}
@@ -0,0 +1,53 @@
+package baudit
+
+import (
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/lbtool/pkg/json"
+	"io"
+	"sync"
+)
+
+// Sink 审计日志的输出
+type Sink interface {
+	Write(rec *Record)
+}
+
+// LogSink 输出到 lbtool 的日志, 未配置 WithSink 时使用, 此时不记录请求与响应
+type LogSink struct{}
+
+var logSink = &LogSink{}
+
+func (s *LogSink) Write(rec *Record) {
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return
+	}
+	log.Infof("audit: %s", buf)
+}
+
+// WriterSink 以 json lines 的格式输出到独立的 writer, 如单独的审计日志文件
+type WriterSink struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Write(rec *Record) {
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return
+	}
+	buf = append(buf, '\n')
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, err = s.w.Write(buf)
+	if err != nil {
+		log.Errorf("err:%v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package bcache
+
+import (
+	"container/list"
+	"fmt"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/micro/bredis"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCapacity = 10000
+	defaultPrefix   = "lb_rsp_cache"
+)
+
+// Entry 缓存的响应, 两种协议的编码都保存
+type Entry struct {
+	ETag      string   `json:"etag"` // 响应内容的摘要, 网关按协议生成各自的 ETag
+	Json      string   `json:"json"`
+	Pb        []byte   `json:"pb"`
+	Tags      []string `json:"tags"`
+	ExpiredAt int64    `json:"expired_at"` // 毫秒时间戳
+}
+
+func (e *Entry) expired(now time.Time) bool {
+	return e.ExpiredAt <= now.UnixMilli()
+}
+
+type lruItem struct {
+	key   string
+	entry *Entry
+}
+
+// Cache 进程内 LRU, 可选以 bredis.Group 作为二级缓存在多个网关间共享
+// 业务代码通过 InvalidateTag 按标签失效
+// 注意: 其他进程的 LRU 只能等待过期, 对一致性要求高的命令请缩短 ttl
+type Cache struct {
+	lock     sync.Mutex
+	capacity int
+	ll       *list.List
+	itemMap  map[string]*list.Element
+	tagMap   map[string]map[string]struct{}
+
+	rds    *bredis.Group
+	prefix string
+}
+
+type Option func(*Cache)
+
+// WithCapacity LRU 的最大条目数, 默认 10000
+func WithCapacity(capacity int) Option {
+	return func(c *Cache) {
+		c.capacity = capacity
+	}
+}
+
+// WithRedis 以 redis 作为二级缓存
+func WithRedis(rds *bredis.Group) Option {
+	return func(c *Cache) {
+		c.rds = rds
+	}
+}
+
+func WithPrefix(prefix string) Option {
+	return func(c *Cache) {
+		c.prefix = prefix
+	}
+}
+
+func New(opts ...Option) *Cache {
+	c := &Cache{
+		capacity: defaultCapacity,
+		ll:       list.New(),
+		itemMap:  map[string]*list.Element{},
+		tagMap:   map[string]map[string]struct{}{},
+		prefix:   defaultPrefix,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Cache) genKey(key string) string {
+	return fmt.Sprintf("%s:%s", c.prefix, key)
+}
+
+func (c *Cache) genTagKey(tag string) string {
+	return fmt.Sprintf("%s:tag:%s", c.prefix, tag)
+}
+
+// Get 先查 LRU, 未命中再查 redis 并回填
+func (c *Cache) Get(key string) (*Entry, bool) {
+	now := time.Now()
+	c.lock.Lock()
+	if elem, ok := c.itemMap[key]; ok {
+		item := elem.Value.(*lruItem)
+		if !item.entry.expired(now) {
+			c.ll.MoveToFront(elem)
+			c.lock.Unlock()
+			return item.entry, true
+		}
+		c.removeElement(elem)
+	}
+	c.lock.Unlock()
+
+	if c.rds == nil {
+		return nil, false
+	}
+	var entry Entry
+	err := c.rds.GetJson(c.genKey(key), &entry)
+	if err != nil {
+		if !c.rds.IsNotFound(err) {
+			log.Errorf("err:%v", err)
+		}
+		return nil, false
+	}
+	if entry.expired(now) {
+		return nil, false
+	}
+	c.setLocal(key, &entry)
+	return &entry, true
+}
+
+// Set 写入缓存, tags 用于之后的按标签失效
+func (c *Cache) Set(key string, entry *Entry, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	entry.ExpiredAt = time.Now().Add(ttl).UnixMilli()
+	c.setLocal(key, entry)
+
+	if c.rds == nil {
+		return
+	}
+	err := c.rds.SetJson(c.genKey(key), entry, ttl)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return
+	}
+	for _, tag := range entry.Tags {
+		tagKey := c.genTagKey(tag)
+		err = c.rds.SAdd(tagKey, key)
+		if err != nil {
+			log.Errorf("err:%v", err)
+			continue
+		}
+		// 标签集合随最新写入的条目续期
+		err = c.rds.Expire(tagKey, ttl)
+		if err != nil {
+			log.Errorf("err:%v", err)
+		}
+	}
+}
+
+// Del 删除指定的缓存
+func (c *Cache) Del(key string) error {
+	c.lock.Lock()
+	if elem, ok := c.itemMap[key]; ok {
+		c.removeElement(elem)
+	}
+	c.lock.Unlock()
+
+	if c.rds == nil {
+		return nil
+	}
+	err := c.rds.Del(c.genKey(key))
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return err
+	}
+	return nil
+}
+
+// InvalidateTag 失效带有任意一个标签的缓存
+func (c *Cache) InvalidateTag(tags ...string) error {
+	c.lock.Lock()
+	for _, tag := range tags {
+		for key := range c.tagMap[tag] {
+			if elem, ok := c.itemMap[key]; ok {
+				c.removeElement(elem)
+			}
+		}
+		delete(c.tagMap, tag)
+	}
+	c.lock.Unlock()
+
+	if c.rds == nil {
+		return nil
+	}
+	for _, tag := range tags {
+		tagKey := c.genTagKey(tag)
+		keyList, err := c.rds.SMembers(tagKey)
+		if err != nil {
+			log.Errorf("err:%v", err)
+			return err
+		}
+		for _, key := range keyList {
+			err = c.rds.Del(c.genKey(key))
+			if err != nil {
+				log.Errorf("err:%v", err)
+				return err
+			}
+		}
+		err = c.rds.Del(tagKey)
+		if err != nil {
+			log.Errorf("err:%v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cache) setLocal(key string, entry *Entry) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.itemMap[key]; ok {
+		c.removeElement(elem)
+	}
+	c.itemMap[key] = c.ll.PushFront(&lruItem{key: key, entry: entry})
+	for _, tag := range entry.Tags {
+		keySet, ok := c.tagMap[tag]
+		if !ok {
+			keySet = map[string]struct{}{}
+			c.tagMap[tag] = keySet
+		}
+		keySet[key] = struct{}{}
+	}
+
+	for c.capacity > 0 && c.ll.Len() > c.capacity {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *Cache) removeElement(elem *list.Element) {
+	item := elem.Value.(*lruItem)
+	c.ll.Remove(elem)
+	delete(c.itemMap, item.key)
+	for _, tag := range item.entry.Tags {
+		keySet, ok := c.tagMap[tag]
+		if !ok {
+			continue
+		}
+		delete(keySet, item.key)
+		if len(keySet) == 0 {
+			delete(c.tagMap, tag)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package bcmd
+
+import (
+	"github.com/oldbai555/micro/baudit"
+	"strconv"
+)
+
+const (
+	AuditSampleRate = "AuditSampleRate" // OptionMap 中声明审计日志采样率, 如 0.1
+	AuditRedact     = "AuditRedact"     // OptionMap 中声明额外脱敏的字段名, 逗号分隔
+)
+
+// GetAuditOption 合并 Audit 与 OptionMap 中的声明
+func (c *Cmd) GetAuditOption() *baudit.CmdOption {
+	var opt baudit.CmdOption
+	if c.Audit != nil {
+		opt = *c.Audit
+	}
+	if opt.SampleRate <= 0 && c.OptionMap != nil {
+		if val, ok := c.OptionMap[AuditSampleRate]; ok {
+			rate, err := strconv.ParseFloat(val, 64)
+			if err == nil {
+				opt.SampleRate = rate
+			}
+		}
+	}
+	opt.RedactFields = mergeOptionList(opt.RedactFields, c.OptionMap, AuditRedact)
+	return &opt
+}
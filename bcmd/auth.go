@@ -0,0 +1,162 @@
+package bcmd
+
+import (
+	"github.com/oldbai555/lbtool/pkg/lberr"
+	"github.com/oldbai555/micro/uctx"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"net/http"
+	"strings"
+)
+
+const (
+	Roles  = "Roles"  // OptionMap 中声明所需角色, 逗号分隔, 命中任意一个即可
+	Scopes = "Scopes" // OptionMap 中声明所需权限范围, 逗号分隔, 需全部满足
+)
+
+const reqFieldCorpId = "corp_id"
+
+// Principal 鉴权链返回的调用方信息
+// checkAuthF / gate.CheckAuthFunc 返回的 extInfo 实现该接口后即可参与权限校验
+type Principal interface {
+	GetRoles() []string
+	GetScopes() []string
+}
+
+// PolicyFunc 基于属性的权限校验, 返回 error 表示拒绝
+type PolicyFunc func(nCtx uctx.IUCtx, cmd *Cmd, req proto.Message) error
+
+func (c *Cmd) WithPolicyF(f PolicyFunc) *Cmd {
+	c.policyF = f
+	return c
+}
+
+// WithPolicyList GinPost 额外校验的策略, 与网关的 gate.WithPolicyFunc 对应
+// 网关不经过 GinPost, 同一份命令列表同时用于两者时传入相同的策略
+func (c *Cmd) WithPolicyList(list ...PolicyFunc) *Cmd {
+	c.policyList = append(c.policyList, list...)
+	return c
+}
+
+func (c *Cmd) GetRoles() []string {
+	return mergeOptionList(c.Roles, c.OptionMap, Roles)
+}
+
+func (c *Cmd) GetScopes() []string {
+	return mergeOptionList(c.Scopes, c.OptionMap, Scopes)
+}
+
+// Authorize 校验调用方是否有权限调用该命令
+// 依次校验 角色 -> 权限范围 -> 命令自身的策略 -> 外部传入的策略
+func (c *Cmd) Authorize(nCtx uctx.IUCtx, req proto.Message, policies ...PolicyFunc) error {
+	roles := c.GetRoles()
+	scopes := c.GetScopes()
+	if len(roles) > 0 || len(scopes) > 0 {
+		principal, ok := nCtx.ExtInfo().(Principal)
+		if !ok {
+			return NewPermissionDeniedErr("principal not found")
+		}
+		if len(roles) > 0 && !containsAny(principal.GetRoles(), roles) {
+			return NewPermissionDeniedErr("required roles %v", roles)
+		}
+		for _, scope := range scopes {
+			if !containsAny(principal.GetScopes(), []string{scope}) {
+				return NewPermissionDeniedErr("required scope %s", scope)
+			}
+		}
+	}
+
+	if c.policyF != nil {
+		err := c.policyF(nCtx, c, req)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, policy := range policies {
+		if policy == nil {
+			continue
+		}
+		err := policy(nCtx, c, req)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NewPermissionDeniedErr(format string, args ...interface{}) error {
+	return lberr.NewErr(http.StatusForbidden, "permission denied: "+format, args...)
+}
+
+func IsPermissionDeniedErr(err error) bool {
+	return lberr.GetErrCode(err) == http.StatusForbidden
+}
+
+// CorpIdPolicy 请求体中带了 corp_id 时, 要求与调用方所属的 corp 一致
+// callerCorpIdF 从上下文中取出调用方的 corp id, 为 nil 时取 nCtx.CorpId(), 可使用 DefaultCallerCorpId
+func CorpIdPolicy(callerCorpIdF func(nCtx uctx.IUCtx) uint32) PolicyFunc {
+	if callerCorpIdF == nil {
+		callerCorpIdF = DefaultCallerCorpId
+	}
+	return func(nCtx uctx.IUCtx, cmd *Cmd, req proto.Message) error {
+		if req == nil {
+			return nil
+		}
+		if nCtx == nil {
+			return NewPermissionDeniedErr("caller not found")
+		}
+		msg := req.ProtoReflect()
+		fd := msg.Descriptor().Fields().ByName(reqFieldCorpId)
+		if fd == nil || !msg.Has(fd) {
+			return nil
+		}
+		var reqCorpId uint64
+		switch fd.Kind() {
+		case protoreflect.Uint32Kind, protoreflect.Uint64Kind, protoreflect.Fixed32Kind, protoreflect.Fixed64Kind:
+			reqCorpId = msg.Get(fd).Uint()
+		case protoreflect.Int32Kind, protoreflect.Int64Kind, protoreflect.Sint32Kind, protoreflect.Sint64Kind:
+			reqCorpId = uint64(msg.Get(fd).Int())
+		default:
+			return nil
+		}
+		if reqCorpId != uint64(callerCorpIdF(nCtx)) {
+			return NewPermissionDeniedErr("corp_id %d not match", reqCorpId)
+		}
+		return nil
+	}
+}
+
+// DefaultCallerCorpId 取 nCtx.CorpId() 作为调用方的 corp id
+func DefaultCallerCorpId(nCtx uctx.IUCtx) uint32 {
+	return nCtx.CorpId()
+}
+
+func mergeOptionList(list []string, optionMap map[string]string, key string) []string {
+	if optionMap == nil {
+		return list
+	}
+	val, ok := optionMap[key]
+	if !ok || val == "" {
+		return list
+	}
+	res := append([]string{}, list...)
+	for _, item := range strings.Split(val, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+func containsAny(have []string, want []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if strings.EqualFold(h, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
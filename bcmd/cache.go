@@ -0,0 +1,69 @@
+package bcmd
+
+import (
+	"github.com/oldbai555/micro/uctx"
+	"google.golang.org/protobuf/proto"
+	"time"
+)
+
+const (
+	CacheTtl  = "CacheTtl"  // OptionMap 中声明响应缓存时间, 如 30s, 声明后即开启缓存
+	CacheVary = "CacheVary" // OptionMap 中声明缓存键区分的 uctx 字段, 逗号分隔, 见 CacheVaryXxx
+	CacheTags = "CacheTags" // OptionMap 中声明缓存标签, 逗号分隔, 用于按标签失效
+)
+
+// 缓存键可区分的 uctx 字段
+const (
+	CacheVarySid      = "sid"
+	CacheVaryDeviceId = "device_id"
+	CacheVaryAuthType = "auth_type"
+	CacheVaryCorp     = "corp"
+)
+
+// CacheOption 只读命令的响应缓存
+type CacheOption struct {
+	Ttl time.Duration
+	// Vary 缓存键区分的 uctx 字段, 需要登录的命令未声明时默认按 sid 区分
+	Vary []string
+	Tags []string
+	// VaryF 自定义的缓存键区分, 如按调用方的角色
+	VaryF func(nCtx uctx.IUCtx) string
+	// TagF 按请求生成的标签, 如 user:{id}
+	TagF func(nCtx uctx.IUCtx, req proto.Message) []string
+
+	private bool // 非 public 的命令, 响应不允许共享缓存保存
+}
+
+// GetCacheOption 合并 Cache 与 OptionMap 中的声明, 未开启缓存时返回 nil
+func (c *Cmd) GetCacheOption() *CacheOption {
+	var opt CacheOption
+	if c.Cache != nil {
+		opt = *c.Cache
+	}
+	if opt.Ttl <= 0 && c.OptionMap != nil {
+		if val, ok := c.OptionMap[CacheTtl]; ok {
+			d, err := time.ParseDuration(val)
+			if err == nil {
+				opt.Ttl = d
+			}
+		}
+	}
+	if opt.Ttl <= 0 {
+		return nil
+	}
+	opt.Vary = mergeOptionList(opt.Vary, c.OptionMap, CacheVary)
+	opt.Tags = mergeOptionList(opt.Tags, c.OptionMap, CacheTags)
+	if !c.IsPublicAuthType() {
+		opt.private = true
+		// 未声明区分字段时按 sid 区分, 避免不同调用方之间串响应
+		if len(opt.Vary) == 0 && opt.VaryF == nil {
+			opt.Vary = []string{CacheVarySid}
+		}
+	}
+	return &opt
+}
+
+// IsPrivate 缓存与会话或调用方相关, Cache-Control 为 private, 不允许共享缓存保存
+func (o *CacheOption) IsPrivate() bool {
+	return o.private || containsAny(o.Vary, []string{CacheVarySid, CacheVaryCorp}) || o.VaryF != nil
+}
@@ -2,10 +2,13 @@ package bcmd
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/oldbai555/micro/baudit"
 	"github.com/oldbai555/micro/uctx"
 	"google.golang.org/protobuf/proto"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -19,19 +22,49 @@ const (
 	AuthTypeSystem = "system"
 )
 
+// HttpRule google.api.http 注解中的参数绑定规则
+type HttpRule struct {
+	Body string // 请求体对应的字段, * 为整个请求, 为空时没有请求体, 未被请求体与路径覆盖的字段来自 query
+}
+
 type Cmd struct {
-	Server     string // 所在服务
-	Path       string // api 请求路径
-	FuncName   string // 方法名
-	OptionMap  map[string]string
-	GRpcFunc   interface{}
-	genIUCtxF  func(ctx *gin.Context) uctx.IUCtx
-	checkAuthF func(nCtx uctx.IUCtx) (extInfo interface{}, err error)
-	errF       func(ctx *gin.Context, err error)
-	resultF    func(ctx *gin.Context, result proto.Message)
+	Server       string            // 所在服务
+	Path         string            // api 请求路径
+	FuncName     string            // 方法名
+	OptionMap    map[string]string // 兼容旧的声明方式, CheckCmdList 时合并到下面的类型化字段
+	GRpcFunc     interface{}
+	Method       string            // http 方法, 默认 POST
+	AuthType     string            // 鉴权类型, 默认 user
+	RateLimit    float64           // 每个 IP 每秒的请求数, 为 0 时不单独限流
+	Deprecated   bool              // 已废弃
+	Version      string            // 命令版本, 如 v2
+	Sunset       time.Time         // 计划下线的时间, 设置后响应带 Sunset 头
+	FullMethod   string            // 网关代理模式下远端方法全名, 如 /user.UserService/GetUser
+	Roles        []string          // 所需角色, 命中任意一个即可
+	Scopes       []string          // 所需权限范围, 需全部满足
+	Timeout      time.Duration     // 处理超时, 为 0 时使用网关的默认值
+	MaxBodyBytes int64             // 请求体上限, 为 0 时使用网关的默认值
+	Idempotent   bool              // 按 Idempotency-Key 请求头去重, 需要网关开启 WithIdempotency
+	Cache        *CacheOption      // 只读命令的响应缓存, 需要网关开启 WithRespCache
+	Audit        *baudit.CmdOption // 审计日志配置, 为 nil 时使用默认配置
+	HttpRule     *HttpRule         // 由 google.api.http 注解生成, 为 nil 时不绑定路径与 query 参数
+	genIUCtxF    func(ctx *gin.Context) uctx.IUCtx
+	checkAuthF   func(nCtx uctx.IUCtx) (extInfo interface{}, err error)
+	errF         func(ctx *gin.Context, err error)
+	resultF      func(ctx *gin.Context, result proto.Message)
+	policyF      PolicyFunc
+	policyList   []PolicyFunc
+	middlewares  []Middleware
+
+	invokerOnce sync.Once
+	invoker     *Invoker
+	invokerErr  error
 }
 
 func (c *Cmd) GetApiMethod() string {
+	if c.Method != "" {
+		return c.Method
+	}
 	if c.OptionMap == nil {
 		return http.MethodPost
 	}
@@ -43,6 +76,9 @@ func (c *Cmd) GetApiMethod() string {
 }
 
 func (c *Cmd) GetAuthType() string {
+	if c.AuthType != "" {
+		return c.AuthType
+	}
 	if c.OptionMap == nil {
 		return AuthTypeUser
 	}
@@ -54,13 +90,24 @@ func (c *Cmd) GetAuthType() string {
 }
 
 func (c *Cmd) IsUserAuthType() bool {
-	return strings.ToUpper(c.GetAuthType()) == strings.ToUpper(AuthTypeUser)
+	return strings.EqualFold(c.GetAuthType(), AuthTypeUser)
 }
 
 func (c *Cmd) IsPublicAuthType() bool {
-	return strings.ToUpper(c.GetAuthType()) == strings.ToUpper(AuthTypePublic)
+	return strings.EqualFold(c.GetAuthType(), AuthTypePublic)
 }
 
 func (c *Cmd) IsSystemAuthType() bool {
-	return strings.ToUpper(c.GetAuthType()) == strings.ToUpper(AuthTypeSystem)
+	return strings.EqualFold(c.GetAuthType(), AuthTypeSystem)
+}
+
+// IsProxy 未提供进程内实现, 由网关转发到 Server 所在的远端服务
+func (c *Cmd) IsProxy() bool {
+	return c.GRpcFunc == nil && c.FullMethod != ""
+}
+
+// IsStream GRpcFunc 为服务端流式方法
+func (c *Cmd) IsStream() bool {
+	_, ok := c.GRpcFunc.(StreamFunc)
+	return ok
 }
@@ -0,0 +1,40 @@
+package bcmd
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// reflectMethodValue reflect.Value.Method(i).Interface() 得到的方法值, 所有方法共用这一个入口
+const reflectMethodValue = "reflect.methodValueCall"
+
+// FuncKey 函数的全名, 用于按函数引用查找命令
+// 方法值 srv.GetUser 与方法表达式 (*Server).GetUser 得到同一个 key, 与接收者无关
+// Typed 包装过的函数取被包装的函数, 非函数与 reflect 生成的方法值返回空串
+func FuncKey(fn interface{}) string {
+	if h, ok := fn.(typedHandler); ok {
+		fn = h.rawFunc()
+	}
+	if fn == nil {
+		return ""
+	}
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+	f := runtime.FuncForPC(v.Pointer())
+	if f == nil {
+		return ""
+	}
+	name := strings.TrimSuffix(f.Name(), "-fm")
+	if name == reflectMethodValue {
+		return ""
+	}
+	return name
+}
+
+// FuncMethodName FuncKey 中的函数名, 如 github.com/a/b.(*Server).GetUser 取 GetUser
+func FuncMethodName(key string) string {
+	return key[strings.LastIndex(key, ".")+1:]
+}
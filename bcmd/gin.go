@@ -11,12 +11,11 @@ import (
 	"github.com/oldbai555/lbtool/log"
 	"github.com/oldbai555/lbtool/pkg/jsonpb"
 	"github.com/oldbai555/lbtool/pkg/lberr"
-	"github.com/oldbai555/micro/brpc/middleware"
+	"github.com/oldbai555/micro/baudit"
 	"github.com/oldbai555/micro/uctx"
 	"google.golang.org/protobuf/proto"
 	"io"
 	"net/http"
-	"reflect"
 )
 
 func (c *Cmd) WithGenIUCtx(genIUCtxF func(ctx *gin.Context) uctx.IUCtx) *Cmd {
@@ -41,16 +40,15 @@ func (c *Cmd) WithHandleResult(handleF func(ctx *gin.Context, result proto.Messa
 
 func (c *Cmd) GinPost(ctx *gin.Context) {
 	// func (a *Server)  RpcFunc(ctx context.Context, req *RpcReq) (*RpcRsp, error)
-
-	// call
-	h := c.GRpcFunc
-	v := reflect.ValueOf(h)
-	t := v.Type()
+	inv, err := c.GetInvoker()
+	if err != nil {
+		log.Errorf("err:%v", err)
+		c.errF(ctx, lberr.NewErr(http.StatusInternalServerError, "internal server error"))
+		return
+	}
 
 	// 拼装 request
-	reqT := t.In(1).Elem()
-	reqV := reflect.New(reqT)
-	msg := reqV.Interface().(proto.Message)
+	msg := inv.NewReq()
 	buff, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
 		log.Errorf("read err:%v", err)
@@ -64,13 +62,11 @@ func (c *Cmd) GinPost(ctx *gin.Context) {
 		return
 	}
 
-	if validator, ok := reqV.Interface().(middleware.Validator); ok {
-		err := validator.Validate()
-		if err != nil {
-			log.Errorf("err:%v", err)
-			c.errF(ctx, err)
-			return
-		}
+	// 参数校验先于登录态校验与鉴权
+	err = inv.Validate(msg)
+	if err != nil {
+		c.errF(ctx, err)
+		return
 	}
 
 	if c.genIUCtxF == nil {
@@ -80,50 +76,59 @@ func (c *Cmd) GinPost(ctx *gin.Context) {
 
 	nCtx := c.genIUCtxF(ctx)
 
+	// 审计日志, 请求与响应经过脱敏与截断
+	var callRes error
+	var rspBody proto.Message
+	audit := baudit.Start(nCtx, c.Path)
+	defer func() {
+		audit.End(c.GetAuditOption(), msg, rspBody, callRes)
+	}()
+
 	// 需要校验
 	if c.IsUserAuthType() {
 		if c.checkAuthF == nil {
-			c.errF(ctx, lberr.NewErr(http.StatusUnauthorized, "unauthorized"))
+			callRes = lberr.NewErr(http.StatusUnauthorized, "unauthorized")
+			c.errF(ctx, callRes)
 			return
 		}
 		extInfo, err := c.checkAuthF(nCtx)
 		if err != nil {
 			log.Errorf("err:%v", err)
-			c.errF(ctx, lberr.NewErr(http.StatusUnauthorized, "unauthorized"))
+			callRes = lberr.NewErr(http.StatusUnauthorized, "unauthorized")
+			c.errF(ctx, callRes)
 			return
 		}
 		nCtx.SetExtInfo(extInfo)
 	}
 
-	log.Infof("req:[%s]", string(buff))
-
-	handlerRet := v.Call([]reflect.Value{reflect.ValueOf(nCtx), reqV})
-
-	// 检查是否有误
-	var callRes error
-	if !handlerRet[1].IsNil() {
-		callRes = handlerRet[1].Interface().(error)
+	// 鉴权, 拒绝时不论 errF 如何响应, 状态码均为 403
+	err = c.Authorize(nCtx, msg, c.policyList...)
+	if err != nil {
+		audit.SetEvent(baudit.EventPermissionDenied)
+		callRes = err
+		ctx.Writer = &forceStatusWriter{ResponseWriter: ctx.Writer, status: http.StatusForbidden}
+		ctx.Status(http.StatusForbidden)
+		c.errF(ctx, err)
+		return
 	}
 
+	// 响应为空的检查在 Call 中完成
+	rspBody, callRes = inv.Call(nCtx, msg)
 	if callRes != nil {
 		log.Errorf("err:%v", callRes)
 		c.errF(ctx, callRes)
 		return
 	}
 
-	// 检查返回值
-	if handlerRet[0].IsValid() && !handlerRet[0].IsNil() {
-		rspBody, ok := handlerRet[0].Interface().(proto.Message)
-		if !ok {
-			log.Errorf("proto convert failed")
-			c.errF(ctx, lberr.NewErr(http.StatusInternalServerError, "internal server error"))
-			return
-		}
+	c.resultF(ctx, rspBody)
+}
 
-		c.resultF(ctx, rspBody)
-		return
-	}
+// forceStatusWriter 忽略 errF 写入的状态码, 以 status 响应
+type forceStatusWriter struct {
+	gin.ResponseWriter
+	status int
+}
 
-	// 走到这里说明走不动了
-	c.errF(ctx, lberr.NewErr(http.StatusInternalServerError, "Internal error"))
+func (w *forceStatusWriter) WriteHeader(int) {
+	w.ResponseWriter.WriteHeader(w.status)
 }
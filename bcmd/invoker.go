@@ -0,0 +1,239 @@
+package bcmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/lbtool/pkg/lberr"
+	"github.com/oldbai555/micro/brpc/middleware"
+	"github.com/oldbai555/micro/uctx"
+	"google.golang.org/protobuf/proto"
+	"net/http"
+	"reflect"
+)
+
+var (
+	ctxType   = reflect.TypeOf((*context.Context)(nil)).Elem()
+	protoType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+	errType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+var ErrNilRsp = lberr.NewErr(http.StatusInternalServerError, "internal error: nil response")
+
+// HandlerFunc 统一的命令调用签名
+type HandlerFunc func(nCtx uctx.IUCtx, req proto.Message) (proto.Message, error)
+
+// Middleware 包裹命令调用的中间件, 与 grpc.UnaryServerInterceptor 类似
+type Middleware func(nCtx uctx.IUCtx, cmd *Cmd, req proto.Message, next HandlerFunc) (proto.Message, error)
+
+// typedHandler 由 Typed 生成, 调用时不经过反射
+type typedHandler interface {
+	newReq() proto.Message
+	newRsp() proto.Message
+	handle(ctx context.Context, req proto.Message) (proto.Message, error)
+	rawFunc() interface{}
+}
+
+type typedFunc[Req any, Rsp any, PReq interface {
+	*Req
+	proto.Message
+}, PRsp interface {
+	*Rsp
+	proto.Message
+}] struct {
+	f func(ctx context.Context, req PReq) (PRsp, error)
+}
+
+func (t *typedFunc[Req, Rsp, PReq, PRsp]) newReq() proto.Message {
+	return PReq(new(Req))
+}
+
+func (t *typedFunc[Req, Rsp, PReq, PRsp]) newRsp() proto.Message {
+	return PRsp(new(Rsp))
+}
+
+func (t *typedFunc[Req, Rsp, PReq, PRsp]) rawFunc() interface{} {
+	return t.f
+}
+
+func (t *typedFunc[Req, Rsp, PReq, PRsp]) handle(ctx context.Context, req proto.Message) (proto.Message, error) {
+	rsp, err := t.f(ctx, req.(PReq))
+	if err != nil {
+		return nil, err
+	}
+	if rsp == nil {
+		return nil, nil
+	}
+	return rsp, nil
+}
+
+// Typed 将 func(ctx, *Req) (*Rsp, error) 包装成 GRpcFunc, 调用时不经过反射
+// 如: GRpcFunc: bcmd.Typed(srv.GetUser)
+func Typed[Req any, Rsp any, PReq interface {
+	*Req
+	proto.Message
+}, PRsp interface {
+	*Rsp
+	proto.Message
+}](f func(ctx context.Context, req PReq) (PRsp, error)) interface{} {
+	return &typedFunc[Req, Rsp, PReq, PRsp]{f: f}
+}
+
+// Invoker 注册时预先解析 GRpcFunc, 请求时不再对函数签名做反射
+type Invoker struct {
+	cmd     *Cmd
+	newReqF func() proto.Message
+	newRspF func() proto.Message
+	handleF HandlerFunc
+}
+
+// NewInvoker 解析 GRpcFunc 并组装中间件, 签名不合法时返回错误
+func NewInvoker(cmd *Cmd, mws ...Middleware) (*Invoker, error) {
+	inv := &Invoker{cmd: cmd}
+	var base HandlerFunc
+	switch h := cmd.GRpcFunc.(type) {
+	case nil:
+		return nil, fmt.Errorf("cmd %s: GRpcFunc is nil", cmd.Path)
+	case StreamFunc:
+		return nil, fmt.Errorf("cmd %s: stream func can not be invoked", cmd.Path)
+	case typedHandler:
+		inv.newReqF = h.newReq
+		inv.newRspF = h.newRsp
+		base = func(nCtx uctx.IUCtx, req proto.Message) (proto.Message, error) {
+			return h.handle(nCtx, req)
+		}
+	default:
+		var err error
+		base, err = inv.reflectHandler()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	next := func(nCtx uctx.IUCtx, req proto.Message) (proto.Message, error) {
+		rsp, err := base(nCtx, req)
+		if err != nil {
+			return nil, err
+		}
+		if rsp == nil {
+			return nil, ErrNilRsp
+		}
+		return rsp, nil
+	}
+
+	inv.handleF = Chain(cmd, next, mws...)
+	return inv, nil
+}
+
+// Chain 以中间件包裹 h, 先传入的在外层, 命令自身的中间件在最内层
+func Chain(cmd *Cmd, h HandlerFunc, mws ...Middleware) HandlerFunc {
+	return chain(cmd, h, append(append([]Middleware{}, mws...), cmd.middlewares...))
+}
+
+func chain(cmd *Cmd, h HandlerFunc, list []Middleware) HandlerFunc {
+	for i := len(list) - 1; i >= 0; i-- {
+		mw, next := list[i], h
+		h = func(nCtx uctx.IUCtx, req proto.Message) (proto.Message, error) {
+			return mw(nCtx, cmd, req, next)
+		}
+	}
+	return h
+}
+
+// Wrap 在已有的中间件外层再包裹 mws, 复用已解析的 GRpcFunc
+func (i *Invoker) Wrap(mws ...Middleware) *Invoker {
+	if len(mws) == 0 {
+		return i
+	}
+	n := *i
+	n.handleF = chain(i.cmd, i.handleF, mws)
+	return &n
+}
+
+// reflectHandler 兼容 func(context.Context, *Req) (*Rsp, error) 形式的 GRpcFunc
+func (i *Invoker) reflectHandler() (HandlerFunc, error) {
+	v := reflect.ValueOf(i.cmd.GRpcFunc)
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 2 || t.NumOut() != 2 {
+		return nil, fmt.Errorf("cmd %s: GRpcFunc must be XX(context.Context, proto.Message)(proto.Message, error)", i.cmd.Path)
+	}
+	if !t.In(0).Implements(ctxType) {
+		return nil, fmt.Errorf("cmd %s: first in arg must be context.Context", i.cmd.Path)
+	}
+	if !t.In(1).Implements(protoType) || t.In(1).Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("cmd %s: second in arg must be proto.Message", i.cmd.Path)
+	}
+	if !t.Out(0).Implements(protoType) || t.Out(0).Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("cmd %s: first out arg must be proto.Message", i.cmd.Path)
+	}
+	if t.Out(1) != errType {
+		return nil, fmt.Errorf("cmd %s: second out arg must be error", i.cmd.Path)
+	}
+
+	reqT := t.In(1).Elem()
+	rspT := t.Out(0).Elem()
+	i.newReqF = func() proto.Message {
+		return reflect.New(reqT).Interface().(proto.Message)
+	}
+	i.newRspF = func() proto.Message {
+		return reflect.New(rspT).Interface().(proto.Message)
+	}
+	return func(nCtx uctx.IUCtx, req proto.Message) (proto.Message, error) {
+		ret := v.Call([]reflect.Value{reflect.ValueOf(nCtx), reflect.ValueOf(req)})
+		if !ret[1].IsNil() {
+			return nil, ret[1].Interface().(error)
+		}
+		if ret[0].IsNil() {
+			return nil, nil
+		}
+		return ret[0].Interface().(proto.Message), nil
+	}, nil
+}
+
+func (i *Invoker) NewReq() proto.Message {
+	return i.newReqF()
+}
+
+func (i *Invoker) NewRsp() proto.Message {
+	return i.newRspF()
+}
+
+// Validate 与 grpc 的 AutoValidate 保持一致
+func (i *Invoker) Validate(req proto.Message) error {
+	if validator, ok := req.(middleware.Validator); ok {
+		err := validator.Validate()
+		if err != nil {
+			log.Errorf("err:%v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// Invoke 参数校验后依次经过中间件调用 GRpcFunc, 响应为 nil 时返回 ErrNilRsp
+func (i *Invoker) Invoke(nCtx uctx.IUCtx, req proto.Message) (proto.Message, error) {
+	err := i.Validate(req)
+	if err != nil {
+		return nil, err
+	}
+	return i.handleF(nCtx, req)
+}
+
+// Call 同 Invoke, 但不做参数校验, 供已提前校验过的调用方使用, 如 GinPost
+func (i *Invoker) Call(nCtx uctx.IUCtx, req proto.Message) (proto.Message, error) {
+	return i.handleF(nCtx, req)
+}
+
+// WithMiddleware 命令级别的中间件
+func (c *Cmd) WithMiddleware(list ...Middleware) *Cmd {
+	c.middlewares = append(c.middlewares, list...)
+	return c
+}
+
+// GetInvoker 首次调用时解析并缓存, GinPost 与网关共用, 网关的中间件通过 Wrap 包裹
+func (c *Cmd) GetInvoker() (*Invoker, error) {
+	c.invokerOnce.Do(func() {
+		c.invoker, c.invokerErr = NewInvoker(c)
+	})
+	return c.invoker, c.invokerErr
+}
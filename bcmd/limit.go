@@ -0,0 +1,62 @@
+package bcmd
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	Timeout      = "Timeout"      // OptionMap 中声明处理超时, 如 3s, 覆盖网关的默认值
+	MaxBodyBytes = "MaxBodyBytes" // OptionMap 中声明请求体上限, 单位字节, 覆盖网关的默认值
+	Idempotent   = "Idempotent"   // OptionMap 中声明为 true 时, 网关按 Idempotency-Key 请求头去重
+)
+
+// GetTimeout 命令的处理超时, 未声明时返回 0, 由网关决定
+func (c *Cmd) GetTimeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	if c.OptionMap == nil {
+		return 0
+	}
+	val, ok := c.OptionMap[Timeout]
+	if !ok {
+		return 0
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// GetMaxBodyBytes 命令的请求体上限, 未声明时返回 0, 由网关决定
+func (c *Cmd) GetMaxBodyBytes() int64 {
+	if c.MaxBodyBytes > 0 {
+		return c.MaxBodyBytes
+	}
+	if c.OptionMap == nil {
+		return 0
+	}
+	val, ok := c.OptionMap[MaxBodyBytes]
+	if !ok {
+		return 0
+	}
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// IsIdempotent 是否开启幂等键去重
+func (c *Cmd) IsIdempotent() bool {
+	if c.Idempotent {
+		return true
+	}
+	if c.OptionMap == nil {
+		return false
+	}
+	ok, _ := strconv.ParseBool(c.OptionMap[Idempotent])
+	return ok
+}
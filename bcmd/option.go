@@ -0,0 +1,232 @@
+package bcmd
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RateLimit  = "RateLimit"  // OptionMap 中声明每个 IP 每秒的请求数
+	Deprecated = "Deprecated" // OptionMap 中声明为 true 时表示命令已废弃
+	Version    = "Version"    // OptionMap 中声明命令版本
+	Sunset     = "Sunset"     // OptionMap 中声明计划下线的时间, RFC3339 或 2006-01-02
+)
+
+var validMethodMap = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+var validAuthTypeMap = map[string]bool{
+	AuthTypeUser:   true,
+	AuthTypePublic: true,
+	AuthTypeSystem: true,
+}
+
+var validCacheVaryMap = map[string]bool{
+	CacheVarySid:      true,
+	CacheVaryDeviceId: true,
+	CacheVaryAuthType: true,
+	CacheVaryCorp:     true,
+}
+
+// NewCmd 配合 WithXxx 以类型化的方式声明命令
+// 如: bcmd.NewCmd("/user/get", bcmd.Typed(srv.GetUser)).WithMethod(http.MethodGet).WithAuthType(bcmd.AuthTypePublic)
+func NewCmd(path string, fn interface{}) *Cmd {
+	return &Cmd{Path: path, GRpcFunc: fn}
+}
+
+func (c *Cmd) WithMethod(method string) *Cmd {
+	c.Method = strings.ToUpper(method)
+	return c
+}
+
+func (c *Cmd) WithAuthType(authType string) *Cmd {
+	c.AuthType = strings.ToLower(authType)
+	return c
+}
+
+func (c *Cmd) WithRateLimit(perSecond float64) *Cmd {
+	c.RateLimit = perSecond
+	return c
+}
+
+func (c *Cmd) WithTimeout(timeout time.Duration) *Cmd {
+	c.Timeout = timeout
+	return c
+}
+
+func (c *Cmd) WithMaxBodyBytes(n int64) *Cmd {
+	c.MaxBodyBytes = n
+	return c
+}
+
+func (c *Cmd) WithCache(opt *CacheOption) *Cmd {
+	c.Cache = opt
+	return c
+}
+
+func (c *Cmd) WithIdempotent() *Cmd {
+	c.Idempotent = true
+	return c
+}
+
+func (c *Cmd) WithDeprecated() *Cmd {
+	c.Deprecated = true
+	return c
+}
+
+func (c *Cmd) WithVersion(version string) *Cmd {
+	c.Version = version
+	return c
+}
+
+// WithSunset 声明计划下线的时间, 同时视为已废弃
+func (c *Cmd) WithSunset(t time.Time) *Cmd {
+	c.Sunset = t
+	return c
+}
+
+// Validate 校验声明并将 OptionMap 合并到类型化字段, 类型化字段优先
+// 合并后请求时不再解析 OptionMap
+func (c *Cmd) Validate() error {
+	if c.Path == "" || !strings.HasPrefix(c.Path, "/") {
+		return fmt.Errorf("cmd %q: path must start with /", c.Path)
+	}
+
+	method := strings.ToUpper(c.GetApiMethod())
+	if !validMethodMap[method] {
+		return fmt.Errorf("cmd %s: invalid method %q", c.Path, method)
+	}
+	c.Method = method
+
+	authType := strings.ToLower(c.GetAuthType())
+	if !validAuthTypeMap[authType] {
+		return fmt.Errorf("cmd %s: invalid auth type %q", c.Path, authType)
+	}
+	c.AuthType = authType
+
+	if c.Timeout == 0 {
+		d, err := c.parseDurationOption(Timeout)
+		if err != nil {
+			return err
+		}
+		c.Timeout = d
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("cmd %s: negative timeout", c.Path)
+	}
+
+	if c.MaxBodyBytes == 0 {
+		if val, ok := c.OptionMap[MaxBodyBytes]; ok {
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return fmt.Errorf("cmd %s: invalid %s %q", c.Path, MaxBodyBytes, val)
+			}
+			c.MaxBodyBytes = n
+		}
+	}
+	if c.MaxBodyBytes < 0 {
+		return fmt.Errorf("cmd %s: negative max body bytes", c.Path)
+	}
+
+	if c.RateLimit == 0 {
+		if val, ok := c.OptionMap[RateLimit]; ok {
+			n, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return fmt.Errorf("cmd %s: invalid %s %q", c.Path, RateLimit, val)
+			}
+			c.RateLimit = n
+		}
+	}
+	if c.RateLimit < 0 {
+		return fmt.Errorf("cmd %s: negative rate limit", c.Path)
+	}
+
+	var err error
+	if !c.Idempotent {
+		c.Idempotent, err = c.parseBoolOption(Idempotent)
+		if err != nil {
+			return err
+		}
+	}
+	if !c.Deprecated {
+		c.Deprecated, err = c.parseBoolOption(Deprecated)
+		if err != nil {
+			return err
+		}
+	}
+	if c.Version == "" {
+		c.Version = c.OptionMap[Version]
+	}
+	if c.Version != "" {
+		c.Version = NormalizeVersion(c.Version)
+		if !versionReg.MatchString(c.Version) {
+			return fmt.Errorf("cmd %s: invalid version %q", c.Path, c.Version)
+		}
+	}
+	if c.Sunset.IsZero() {
+		if val, ok := c.OptionMap[Sunset]; ok {
+			c.Sunset, err = parseSunset(val)
+			if err != nil {
+				return fmt.Errorf("cmd %s: invalid %s %q", c.Path, Sunset, val)
+			}
+		}
+	}
+
+	if c.Cache == nil || c.Cache.Ttl <= 0 {
+		_, err = c.parseDurationOption(CacheTtl)
+		if err != nil {
+			return err
+		}
+	}
+	if cacheOpt := c.GetCacheOption(); cacheOpt != nil {
+		for _, vary := range cacheOpt.Vary {
+			if !validCacheVaryMap[strings.ToLower(vary)] {
+				return fmt.Errorf("cmd %s: invalid cache vary %q", c.Path, vary)
+			}
+		}
+	}
+
+	if val, ok := c.OptionMap[AuditSampleRate]; ok {
+		rate, err := strconv.ParseFloat(val, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return fmt.Errorf("cmd %s: invalid %s %q", c.Path, AuditSampleRate, val)
+		}
+	}
+
+	if c.IsStream() && (c.Idempotent || c.GetCacheOption() != nil) {
+		return fmt.Errorf("cmd %s: stream cmd does not support cache or idempotency", c.Path)
+	}
+	return nil
+}
+
+func (c *Cmd) parseDurationOption(key string) (time.Duration, error) {
+	val, ok := c.OptionMap[key]
+	if !ok {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("cmd %s: invalid %s %q", c.Path, key, val)
+	}
+	return d, nil
+}
+
+func (c *Cmd) parseBoolOption(key string) (bool, error) {
+	val, ok := c.OptionMap[key]
+	if !ok {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("cmd %s: invalid %s %q", c.Path, key, val)
+	}
+	return b, nil
+}
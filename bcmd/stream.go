@@ -0,0 +1,14 @@
+package bcmd
+
+import "google.golang.org/grpc"
+
+// StreamFunc 服务端流式方法, 网关以 SSE / WebSocket 的形式暴露
+type StreamFunc func(stream grpc.ServerStream) error
+
+// NewStreamFunc 由 grpc.ServiceDesc 中的 StreamDesc 生成 StreamFunc
+// 如: bcmd.NewStreamFunc(srv, pb.UserService_ServiceDesc.Streams[0].Handler)
+func NewStreamFunc(srv interface{}, handler grpc.StreamHandler) StreamFunc {
+	return func(stream grpc.ServerStream) error {
+		return handler(srv, stream)
+	}
+}
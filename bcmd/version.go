@@ -0,0 +1,63 @@
+package bcmd
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// versionReg 版本号形如 v1, v2.1
+var versionReg = regexp.MustCompile(`^v\d+(\.\d+)*$`)
+
+// NormalizeVersion 统一为小写并补齐 v 前缀, 如 2 -> v2
+func NormalizeVersion(version string) string {
+	version = strings.ToLower(strings.TrimSpace(version))
+	if version != "" && version[0] >= '0' && version[0] <= '9' {
+		version = "v" + version
+	}
+	return version
+}
+
+// CompareVersion 按数字逐段比较, a < b 返回 -1, 相等返回 0, a > b 返回 1
+func CompareVersion(a, b string) int {
+	aList := strings.Split(strings.TrimPrefix(NormalizeVersion(a), "v"), ".")
+	bList := strings.Split(strings.TrimPrefix(NormalizeVersion(b), "v"), ".")
+	for i := 0; i < len(aList) || i < len(bList); i++ {
+		var x, y int
+		if i < len(aList) {
+			x, _ = strconv.Atoi(aList[i])
+		}
+		if i < len(bList) {
+			y, _ = strconv.Atoi(bList[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// VersionedPath 带版本前缀的路径, 如 /v2/user/get, 未声明版本时即 Path
+func (c *Cmd) VersionedPath() string {
+	if c.Version == "" {
+		return c.Path
+	}
+	return "/" + c.Version + c.Path
+}
+
+// IsDeprecated 声明了 Deprecated 或 Sunset 的命令视为已废弃
+func (c *Cmd) IsDeprecated() bool {
+	return c.Deprecated || !c.Sunset.IsZero()
+}
+
+func parseSunset(val string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, val)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", val)
+}
@@ -4,11 +4,15 @@ import "strings"
 
 var (
 	LogWithHint = strings.ToUpper("hint")
+	// GinCtxErrCode gin.Context 中记录响应的 errcode, 供监控统计
+	GinCtxErrCode = "LB_ERRCODE"
 
 	GinHeaderTraceId  = strings.ToUpper("X-LB-TRACE-ID")
 	GinHeaderDeviceId = strings.ToUpper("X-LB-DEVICE-ID")
 	GinHeaderSid      = strings.ToUpper("X-LB-SID")
 	GinHeaderAuthType = strings.ToUpper("X-LB-AUTH-TYPE")
+	GinHeaderCaller   = strings.ToUpper("X-LB-CALLER") // 调用方标识, 如 ios/3.2.0
+	GinHeaderLocale   = strings.ToUpper("X-LB-LOCALE")
 )
 
 var (
@@ -16,12 +20,24 @@ var (
 	GrpcHeaderDeviceId = strings.ToUpper("X-GRPC-DEVICE-ID")
 	GrpcHeaderSid      = strings.ToUpper("X-GRPC-SID")
 	GrpcHeaderAuthType = strings.ToUpper("X-GRPC-AUTH-TYPE")
+	GrpcHeaderUserId   = strings.ToUpper("X-GRPC-USER-ID")
+	GrpcHeaderCorpId   = strings.ToUpper("X-GRPC-CORP-ID")
+	GrpcHeaderAppId    = strings.ToUpper("X-GRPC-APP-ID")
+	GrpcHeaderRoles    = strings.ToUpper("X-GRPC-ROLES")
+	GrpcHeaderLocale   = strings.ToUpper("X-GRPC-LOCALE")
+	GrpcHeaderClientIp = strings.ToUpper("X-GRPC-CLIENT-IP")
 )
 
 var (
 	ProtocolType = strings.ToUpper("X-LB-PROTO-TYPE")
 )
 
+// ProtoOptionAuthType 方法上声明鉴权类型的 option 扩展全名
+const ProtoOptionAuthType = "lb.api.auth_type"
+
+// ProtoOptionSensitive 字段上声明为 true 时, 审计日志中脱敏
+const ProtoOptionSensitive = "lb.api.sensitive"
+
 const (
 	PROTO_TYPE_PROTO3   = "proto"
 	PROTO_TYPE_API_JSON = "apijson"
@@ -44,6 +60,24 @@ const (
 	HeaderAccessControlAllowMethods     = "Access-Control-Allow-Methods"
 	HeaderAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
 	HeaderAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	HeaderAccessControlMaxAge           = "Access-Control-Max-Age"
+	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
+	HeaderAccessControlRequestHeaders   = "Access-Control-Request-Headers"
+	HeaderOrigin                        = "Origin"
+	HeaderVary                          = "Vary"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+	HeaderETag               = "ETag"
+	HeaderIfNoneMatch        = "If-None-Match"
+	HeaderCacheControl       = "Cache-Control"
+	HeaderLbCache            = "X-LB-CACHE"
+	HeaderAcceptVersion      = "Accept-Version"
+	HeaderDeprecation        = "Deprecation"
+	HeaderSunset             = "Sunset"
+	HeaderAcceptLanguage     = "Accept-Language"
 )
 
 const (
@@ -0,0 +1,247 @@
+package bgin
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/micro/bconst"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CorsConfig 跨域配置
+type CorsConfig struct {
+	// AllowOrigins 允许的来源, 支持精确匹配, "*" 放行全部, "https://*.example.com" 匹配任意子域名
+	AllowOrigins []string
+	// AllowOriginRegex 以正则匹配来源, 如 ^https://.*\.example\.com$
+	AllowOriginRegex []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	// AllowCredentials 为 true 时回写请求的 Origin, 不会输出 "*"
+	AllowCredentials bool
+	// MaxAge 预检结果的缓存时间, 为 0 时不输出
+	MaxAge time.Duration
+}
+
+// DefaultCorsConfig 放行全部来源, 不携带凭证, 默认允许 X-LB-* 请求头
+func DefaultCorsConfig() CorsConfig {
+	return CorsConfig{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{
+			http.MethodGet, http.MethodPost, http.MethodPut,
+			http.MethodPatch, http.MethodDelete, http.MethodOptions,
+		},
+		AllowHeaders: []string{
+			"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With",
+			bconst.GinHeaderTraceId, bconst.GinHeaderDeviceId, bconst.GinHeaderSid,
+			bconst.GinHeaderAuthType, bconst.GinHeaderCaller, bconst.GinHeaderLocale, bconst.ProtocolType,
+			bconst.HeaderAcceptVersion, bconst.HeaderIdempotencyKey,
+		},
+		ExposeHeaders: []string{
+			"Content-Length", "Content-Type", bconst.ProtocolType, bconst.GinHeaderTraceId,
+			bconst.HeaderDeprecation, bconst.HeaderSunset, bconst.HeaderIdempotentReplayed,
+			bconst.HeaderETag, bconst.HeaderLbCache,
+		},
+		MaxAge: time.Hour * 12,
+	}
+}
+
+type corsPolicy struct {
+	allowAll    bool
+	exactMap    map[string]bool
+	wildcard    [][2]string // scheme://*.domain 拆成前缀与后缀
+	regList     []*regexp.Regexp
+	methodMap   map[string]bool
+	headerMap   map[string]bool
+	methods     string
+	headers     string
+	expose      string
+	maxAge      string
+	credentials bool
+}
+
+func newCorsPolicy(conf CorsConfig) *corsPolicy {
+	p := &corsPolicy{
+		exactMap:    map[string]bool{},
+		methodMap:   map[string]bool{},
+		headerMap:   map[string]bool{},
+		credentials: conf.AllowCredentials,
+	}
+	for _, origin := range conf.AllowOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch {
+		case origin == "*":
+			p.allowAll = true
+		case strings.Contains(origin, "*"):
+			idx := strings.Index(origin, "*")
+			p.wildcard = append(p.wildcard, [2]string{origin[:idx], origin[idx+1:]})
+		case origin != "":
+			p.exactMap[origin] = true
+		}
+	}
+	for _, expr := range conf.AllowOriginRegex {
+		p.regList = append(p.regList, regexp.MustCompile(expr))
+	}
+
+	var methods []string
+	for _, method := range conf.AllowMethods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if method == "" || p.methodMap[method] {
+			continue
+		}
+		p.methodMap[method] = true
+		methods = append(methods, method)
+	}
+	var headers []string
+	for _, header := range conf.AllowHeaders {
+		header = http.CanonicalHeaderKey(strings.TrimSpace(header))
+		if header == "" || p.headerMap[header] {
+			continue
+		}
+		p.headerMap[header] = true
+		headers = append(headers, header)
+	}
+	var expose []string
+	for _, header := range conf.ExposeHeaders {
+		expose = append(expose, http.CanonicalHeaderKey(strings.TrimSpace(header)))
+	}
+	p.methods = strings.Join(methods, ", ")
+	p.headers = strings.Join(headers, ", ")
+	p.expose = strings.Join(expose, ", ")
+	if conf.MaxAge > 0 {
+		p.maxAge = strconv.FormatInt(int64(conf.MaxAge/time.Second), 10)
+	}
+	return p
+}
+
+func (p *corsPolicy) isOriginAllowed(origin string) bool {
+	if p.allowAll {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if p.exactMap[lower] {
+		return true
+	}
+	for _, w := range p.wildcard {
+		// 通配符至少匹配一级子域名
+		if len(lower) > len(w[0])+len(w[1]) && strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) {
+			return true
+		}
+	}
+	for _, reg := range p.regList {
+		if reg.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *corsPolicy) isHeadersAllowed(requestHeaders string) bool {
+	for _, header := range strings.Split(requestHeaders, ",") {
+		header = http.CanonicalHeaderKey(strings.TrimSpace(header))
+		if header == "" {
+			continue
+		}
+		if !p.headerMap[header] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *corsPolicy) setOriginHeader(c *gin.Context, origin string) {
+	if p.allowAll && !p.credentials {
+		c.Header(bconst.HeaderAccessControlAllowOrigin, "*")
+	} else {
+		// 浏览器不接受 "*" 与 Allow-Credentials 同时出现, 回写具体的来源
+		c.Header(bconst.HeaderAccessControlAllowOrigin, origin)
+		c.Writer.Header().Add(bconst.HeaderVary, bconst.HeaderOrigin)
+	}
+	if p.credentials {
+		c.Header(bconst.HeaderAccessControlAllowCredentials, "true")
+	}
+}
+
+func (p *corsPolicy) handle(c *gin.Context) {
+	origin := c.GetHeader(bconst.HeaderOrigin)
+	// 非跨域请求
+	if origin == "" {
+		c.Next()
+		return
+	}
+
+	preflight := c.Request.Method == http.MethodOptions && c.GetHeader(bconst.HeaderAccessControlRequestMethod) != ""
+	if !p.isOriginAllowed(origin) {
+		log.Warnf("cors: origin %s not allowed, path:%s", origin, c.Request.URL.Path)
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	if !preflight {
+		p.setOriginHeader(c, origin)
+		if p.expose != "" {
+			c.Header(bconst.HeaderAccessControlExposeHeaders, p.expose)
+		}
+		c.Next()
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Add(bconst.HeaderVary, bconst.HeaderAccessControlRequestMethod)
+	header.Add(bconst.HeaderVary, bconst.HeaderAccessControlRequestHeaders)
+
+	method := strings.ToUpper(c.GetHeader(bconst.HeaderAccessControlRequestMethod))
+	if !p.methodMap[method] {
+		log.Warnf("cors: method %s not allowed, origin:%s path:%s", method, origin, c.Request.URL.Path)
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+	requestHeaders := c.GetHeader(bconst.HeaderAccessControlRequestHeaders)
+	if !p.isHeadersAllowed(requestHeaders) {
+		log.Warnf("cors: headers %s not allowed, origin:%s path:%s", requestHeaders, origin, c.Request.URL.Path)
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	p.setOriginHeader(c, origin)
+	c.Header(bconst.HeaderAccessControlAllowMethods, p.methods)
+	if p.headers != "" {
+		c.Header(bconst.HeaderAccessControlAllowHeaders, p.headers)
+	}
+	if p.maxAge != "" {
+		c.Header(bconst.HeaderAccessControlMaxAge, p.maxAge)
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// NewWebSocketOriginChecker WebSocket 握手不受 CORS 约束, 浏览器会带上任意站点的 Origin, 需要单独校验
+// 未带 Origin 与同源的请求放行, 跨域时只放行 AllowOrigins 中明确配置的来源与 AllowOriginRegex, "*" 不放行
+func NewWebSocketOriginChecker(conf CorsConfig) func(r *http.Request) bool {
+	p := newCorsPolicy(conf)
+	p.allowAll = false
+	return func(r *http.Request) bool {
+		origin := r.Header.Get(bconst.HeaderOrigin)
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+		return p.isOriginAllowed(origin)
+	}
+}
+
+// Cors 跨域配制, 使用 DefaultCorsConfig
+func Cors() gin.HandlerFunc {
+	return CorsWithConfig(DefaultCorsConfig())
+}
+
+// CorsWithConfig 按配置处理跨域, 预检请求直接响应 204, 不允许的来源 / 方法 / 请求头响应 403
+func CorsWithConfig(conf CorsConfig) gin.HandlerFunc {
+	return newCorsPolicy(conf).handle
+}
@@ -0,0 +1,45 @@
+package bgin
+
+import (
+	"github.com/oldbai555/lbtool/pkg/lberr"
+)
+
+// IHttpStatusErr 实现该接口的错误, Handler.Error 会以 HttpStatus() 作为 http 状态码响应
+type IHttpStatusErr interface {
+	error
+	HttpStatus() int
+}
+
+var _ IHttpStatusErr = (*HttpStatusErr)(nil)
+
+// HttpStatusErr 需要以指定 http 状态码响应的错误, 如 408 / 413 / 504
+// errcode 与 http 状态码一致
+type HttpStatusErr struct {
+	status int
+	err    error
+}
+
+func NewHttpStatusErr(status int, format string, args ...interface{}) *HttpStatusErr {
+	return &HttpStatusErr{
+		status: status,
+		err:    lberr.NewErr(int32(status), format, args...),
+	}
+}
+
+func (e *HttpStatusErr) Error() string {
+	return e.err.Error()
+}
+
+func (e *HttpStatusErr) Unwrap() error {
+	return e.err
+}
+
+func (e *HttpStatusErr) HttpStatus() int {
+	return e.status
+}
+
+// iCodeErr 自带错误码的错误, 如 gormx 的 QueryCanceledErr
+type iCodeErr interface {
+	Code() int32
+	Message() string
+}
@@ -0,0 +1,100 @@
+package gate
+
+import (
+	"bytes"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/oldbai555/lbtool/pkg/jsonpb"
+	"github.com/oldbai555/micro/baudit"
+	"github.com/oldbai555/micro/bcmd"
+	"github.com/oldbai555/micro/bconst"
+	"github.com/oldbai555/micro/uctx"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func echo(_ context.Context, req *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	return wrapperspb.String(req.GetValue()), nil
+}
+
+// wrapperspb 的 json 形式为裸值
+const benchBody = `"hello"`
+
+func newBenchGinPostCmd(fn interface{}) *bcmd.Cmd {
+	return bcmd.NewCmd("/echo", fn).
+		WithAuthType(bcmd.AuthTypePublic).
+		WithGenIUCtx(func(c *gin.Context) uctx.IUCtx {
+			return uctx.New(c.Request.Context())
+		}).
+		WithHandleError(func(c *gin.Context, err error) {
+			c.String(http.StatusInternalServerError, err.Error())
+		}).
+		WithHandleResult(func(c *gin.Context, result proto.Message) {
+			buf, _ := jsonpb.Marshal(result)
+			c.Data(http.StatusOK, "application/json", buf)
+		})
+}
+
+func newBenchGateRouter(fn interface{}) *gin.Engine {
+	cmd := bcmd.NewCmd("/echo", fn).WithAuthType(bcmd.AuthTypePublic)
+	CheckCmdList([]*bcmd.Cmd{cmd})
+	// 关闭审计日志的采样, 只测量命令的分发
+	s := NewSvr("bench", 0, []*bcmd.Cmd{cmd}, nil, WithAuditor(baudit.NewAuditor(baudit.WithSampleRate(0))))
+	router := gin.New()
+	router.POST(cmd.Path, s.newCmdHandler(cmd))
+	return router
+}
+
+func runBench(b *testing.B, router *gin.Engine) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewBufferString(benchBody))
+		req.Header.Set(bconst.ProtocolType, bconst.PROTO_TYPE_API_JSON)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			b.Fatalf("status %d: %s", w.Code, w.Body.String())
+		}
+	}
+}
+
+func BenchmarkGinPost(b *testing.B) {
+	gin.SetMode(gin.ReleaseMode)
+	// GinPost 使用默认的 Auditor
+	defaultAuditor := baudit.Default()
+	baudit.SetDefault(baudit.NewAuditor(baudit.WithSampleRate(0)))
+	defer baudit.SetDefault(defaultAuditor)
+	for _, bc := range []struct {
+		name string
+		fn   interface{}
+	}{
+		{"Reflect", echo},
+		{"Typed", bcmd.Typed(echo)},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			cmd := newBenchGinPostCmd(bc.fn)
+			router := gin.New()
+			router.POST(cmd.Path, cmd.GinPost)
+			runBench(b, router)
+		})
+	}
+}
+
+func BenchmarkGateInvoker(b *testing.B) {
+	gin.SetMode(gin.ReleaseMode)
+	for _, bc := range []struct {
+		name string
+		fn   interface{}
+	}{
+		{"Reflect", echo},
+		{"Typed", bcmd.Typed(echo)},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			runBench(b, newBenchGateRouter(bc.fn))
+		})
+	}
+}
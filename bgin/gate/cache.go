@@ -0,0 +1,172 @@
+package gate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/lbtool/pkg/jsonpb"
+	"github.com/oldbai555/micro/bcache"
+	"github.com/oldbai555/micro/bcmd"
+	"github.com/oldbai555/micro/bconst"
+	"github.com/oldbai555/micro/bgin"
+	"google.golang.org/protobuf/proto"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	cacheHit  = "HIT"
+	cacheMiss = "MISS"
+)
+
+// cacheTicket 未命中缓存的请求, 处理成功后写入缓存
+type cacheTicket struct {
+	key  string
+	opt  *bcmd.CacheOption
+	tags []string
+}
+
+// genCacheKey 由路径, 规范化后的请求与声明的 uctx 字段生成缓存键
+func genCacheKey(nCtx *GinUCtx, cmd *bcmd.Cmd, opt *bcmd.CacheOption, msg proto.Message) (string, error) {
+	buf, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(cmd.VersionedPath()))
+	h.Write([]byte{0})
+	h.Write(buf)
+	for _, vary := range opt.Vary {
+		var val string
+		switch strings.ToLower(vary) {
+		case bcmd.CacheVarySid:
+			val = nCtx.Sid()
+		case bcmd.CacheVaryDeviceId:
+			val = nCtx.DeviceId()
+		case bcmd.CacheVaryAuthType:
+			val = nCtx.AuthType()
+		case bcmd.CacheVaryCorp:
+			val = strconv.FormatUint(uint64(nCtx.CorpId()), 10)
+		default:
+			log.Warnf("cache: unknown vary %s of %s", vary, cmd.Path)
+		}
+		h.Write([]byte{0})
+		h.Write([]byte(val))
+	}
+	if opt.VaryF != nil {
+		h.Write([]byte{0})
+		h.Write([]byte(opt.VaryF(nCtx)))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// lookupCache 命中时直接响应, 返回的 ticket 为 nil 表示该请求不参与缓存
+func (s *Svr) lookupCache(c *gin.Context, nCtx *GinUCtx, cmd *bcmd.Cmd, msg proto.Message) (ticket *cacheTicket, hit bool, err error) {
+	if s.respCache == nil {
+		return nil, false, nil
+	}
+	opt := cmd.GetCacheOption()
+	if opt == nil {
+		return nil, false, nil
+	}
+	key, err := genCacheKey(nCtx, cmd, opt, msg)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, false, err
+	}
+
+	ticket = &cacheTicket{key: key, opt: opt, tags: opt.Tags}
+	if opt.TagF != nil {
+		ticket.tags = append(append([]string{}, opt.Tags...), opt.TagF(nCtx, msg)...)
+	}
+
+	// 客户端要求跳过缓存
+	if strings.Contains(c.GetHeader(bconst.HeaderCacheControl), "no-cache") {
+		return ticket, false, nil
+	}
+	entry, ok := s.respCache.Get(key)
+	if !ok {
+		return ticket, false, nil
+	}
+	writeCacheEntry(c, nCtx, opt, entry, cacheHit)
+	return nil, true, nil
+}
+
+// storeCache 写入缓存并响应, 未命中时同样支持 304
+func (s *Svr) storeCache(c *gin.Context, nCtx *GinUCtx, ticket *cacheTicket, rsp proto.Message) error {
+	pb, err := proto.Marshal(rsp)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return err
+	}
+	js, err := jsonpb.MarshalToString(rsp)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return err
+	}
+	sum := sha256.Sum256(pb)
+	entry := &bcache.Entry{
+		ETag: hex.EncodeToString(sum[:16]),
+		Json: js,
+		Pb:   pb,
+		Tags: ticket.tags,
+	}
+	s.respCache.Set(ticket.key, entry, ticket.opt.Ttl)
+	writeCacheEntry(c, nCtx, ticket.opt, entry, cacheMiss)
+	return nil
+}
+
+func writeCacheEntry(c *gin.Context, nCtx *GinUCtx, opt *bcmd.CacheOption, entry *bcache.Entry, state string) {
+	maxAge := time.Until(time.UnixMilli(entry.ExpiredAt)).Round(time.Second) / time.Second
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	visibility := "public"
+	if opt.IsPrivate() {
+		visibility = "private"
+	}
+	// 同一缓存按请求的协议输出 json 或 pb, 共享缓存需要按协议区分
+	protocolType := bconst.PROTO_TYPE_PROTO3
+	if nCtx.ProtocolType() == bconst.PROTO_TYPE_API_JSON {
+		protocolType = bconst.PROTO_TYPE_API_JSON
+	}
+	etag := fmt.Sprintf(`"%s-%s"`, entry.ETag, protocolType)
+	c.Header(bconst.HeaderETag, etag)
+	c.Header(bconst.HeaderCacheControl, fmt.Sprintf("%s, max-age=%d", visibility, maxAge))
+	c.Header(bconst.HeaderLbCache, state)
+	c.Writer.Header().Add(bconst.HeaderVary, bconst.ProtocolType)
+
+	if etagMatch(c.GetHeader(bconst.HeaderIfNoneMatch), etag) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+
+	if protocolType == bconst.PROTO_TYPE_API_JSON {
+		bgin.NewHandler(c).RespByJson(http.StatusOK, 0, entry.Json, bconst.DefaultRspMsg)
+		return
+	}
+	c.Header(bconst.ProtocolType, bconst.PROTO_TYPE_PROTO3)
+	_, err := c.Writer.Write(entry.Pb)
+	if err != nil {
+		log.Errorf("err:%v", err)
+	}
+}
+
+// etagMatch If-None-Match 采用弱比较
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
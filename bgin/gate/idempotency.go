@@ -0,0 +1,170 @@
+package gate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/lbtool/pkg/json"
+	"github.com/oldbai555/lbtool/pkg/jsonpb"
+	"github.com/oldbai555/micro/bcmd"
+	"github.com/oldbai555/micro/bconst"
+	"github.com/oldbai555/micro/bgin"
+	"github.com/oldbai555/micro/bredis"
+	"google.golang.org/protobuf/proto"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultIdempotencyTtl    = time.Hour * 24
+	defaultIdempotencyPrefix = "lb_idempotency"
+	maxIdempotencyKeyLen     = 255
+)
+
+// idempotency 幂等键去重
+// 首次请求以 SetNX 占位, 处理成功后写入响应; 重复请求直接回放响应, 首次请求未完成时返回 409
+type idempotency struct {
+	rds    *bredis.Group
+	ttl    time.Duration
+	prefix string
+}
+
+// idempotencyTicket 占位成功的幂等键
+type idempotencyTicket struct {
+	rdsKey  string
+	reqHash string
+}
+
+type idempotencyRecord struct {
+	Done    bool   `json:"done"`
+	ReqHash string `json:"req_hash"`
+	Json    string `json:"json"`
+	Pb      []byte `json:"pb"`
+}
+
+// genKey 幂等键按调用方隔离, 优先取 sid, 没有登录态时取设备 id, 再没有时取客户端 ip
+// 避免匿名调用方之间用相同的键互相回放响应
+func (i *idempotency) genKey(nCtx *GinUCtx, cmd *bcmd.Cmd, key string) string {
+	var scope string
+	switch {
+	case nCtx.Sid() != "":
+		scope = "sid_" + nCtx.Sid()
+	case nCtx.DeviceId() != "":
+		scope = "dev_" + nCtx.DeviceId()
+	default:
+		scope = "ip_" + nCtx.ClientIp()
+	}
+	return fmt.Sprintf("%s:%s:%s:%s", i.prefix, scope, cmd.VersionedPath(), key)
+}
+
+func hashReq(msg proto.Message) (string, error) {
+	buf, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// reserveIdempotency 占位幂等键, replayed 为 true 时已回放了之前的响应
+// 返回的 ticket 为 nil 表示该请求不参与去重
+func (s *Svr) reserveIdempotency(c *gin.Context, nCtx *GinUCtx, cmd *bcmd.Cmd, msg proto.Message) (ticket *idempotencyTicket, replayed bool, err error) {
+	if s.idempotency == nil || !cmd.IsIdempotent() {
+		return nil, false, nil
+	}
+	key := c.GetHeader(bconst.HeaderIdempotencyKey)
+	if key == "" {
+		return nil, false, nil
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		return nil, false, bgin.NewHttpStatusErr(http.StatusBadRequest, "idempotency key too long")
+	}
+
+	reqHash, err := hashReq(msg)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, false, err
+	}
+
+	i := s.idempotency
+	rdsKey := i.genKey(nCtx, cmd, key)
+	val, err := json.Marshal(&idempotencyRecord{ReqHash: reqHash})
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, false, err
+	}
+	ok, err := i.rds.SetNX(rdsKey, val, i.ttl)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, false, err
+	}
+	if ok {
+		return &idempotencyTicket{rdsKey: rdsKey, reqHash: reqHash}, false, nil
+	}
+
+	var record idempotencyRecord
+	err = i.rds.GetJson(rdsKey, &record)
+	if err != nil {
+		if i.rds.IsNotFound(err) {
+			// 占位刚好过期, 让客户端重试
+			return nil, false, bgin.NewHttpStatusErr(http.StatusConflict, "idempotency key expired, please retry")
+		}
+		log.Errorf("err:%v", err)
+		return nil, false, err
+	}
+	if record.ReqHash != reqHash {
+		return nil, false, bgin.NewHttpStatusErr(http.StatusUnprocessableEntity, "idempotency key reused with different request")
+	}
+	if !record.Done {
+		return nil, false, bgin.NewHttpStatusErr(http.StatusConflict, "request with the same idempotency key is in progress")
+	}
+
+	log.Infof("idempotency: replay path:%s key:%s", cmd.Path, key)
+	c.Header(bconst.HeaderIdempotentReplayed, "true")
+	if nCtx.ProtocolType() == bconst.PROTO_TYPE_API_JSON {
+		bgin.NewHandler(c).RespByJson(http.StatusOK, 0, record.Json, bconst.DefaultRspMsg)
+		return nil, true, nil
+	}
+	c.Header(bconst.ProtocolType, bconst.PROTO_TYPE_PROTO3)
+	_, err = c.Writer.Write(record.Pb)
+	if err != nil {
+		log.Errorf("err:%v", err)
+	}
+	return nil, true, nil
+}
+
+// saveIdempotency 处理成功后写入响应, 两种协议的编码都保存, 回放时按请求的协议输出
+func (s *Svr) saveIdempotency(ticket *idempotencyTicket, rsp proto.Message) {
+	if ticket == nil {
+		return
+	}
+	var err error
+	record := &idempotencyRecord{Done: true, ReqHash: ticket.reqHash}
+	record.Json, err = jsonpb.MarshalToString(rsp)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return
+	}
+	record.Pb, err = proto.Marshal(rsp)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return
+	}
+	err = s.idempotency.rds.SetJson(ticket.rdsKey, record, s.idempotency.ttl)
+	if err != nil {
+		log.Errorf("err:%v", err)
+	}
+}
+
+// releaseIdempotency 处理失败时释放占位, 允许客户端用同一个键重试
+func (s *Svr) releaseIdempotency(ticket *idempotencyTicket) {
+	if ticket == nil {
+		return
+	}
+	err := s.idempotency.rds.Del(ticket.rdsKey)
+	if err != nil {
+		log.Errorf("err:%v", err)
+	}
+}
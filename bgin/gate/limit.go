@@ -0,0 +1,134 @@
+package gate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/oldbai555/micro/bcmd"
+	"github.com/oldbai555/micro/bgin"
+	"github.com/oldbai555/micro/uctx"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	defaultReadHeaderTimeout = time.Second * 10
+	defaultIdleTimeout       = time.Second * 60
+	defaultMaxHeaderBytes    = 1 << 20
+	defaultMaxBodyBytes      = 4 << 20 // 与 grpc 默认的最大接收大小一致
+
+	// statusClientClosedRequest 客户端已断开, 与 nginx 的 499 保持一致
+	statusClientClosedRequest = 499
+)
+
+// newHttpSrv 按配置组装 http.Server, ReadHeaderTimeout 用于防止 slowloris
+// 注意 WriteTimeout 对 SSE 同样生效, 有流式命令时建议保持为 0
+func (s *Svr) newHttpSrv(handler http.Handler) *http.Server {
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", s.port),
+		Handler:           handler,
+		ReadHeaderTimeout: s.readHeaderTimeout,
+		ReadTimeout:       s.readTimeout,
+		WriteTimeout:      s.writeTimeout,
+		IdleTimeout:       s.idleTimeout,
+		MaxHeaderBytes:    s.maxHeaderBytes,
+	}
+	if srv.ReadHeaderTimeout <= 0 {
+		srv.ReadHeaderTimeout = defaultReadHeaderTimeout
+	}
+	if srv.IdleTimeout <= 0 {
+		srv.IdleTimeout = defaultIdleTimeout
+	}
+	if srv.MaxHeaderBytes <= 0 {
+		srv.MaxHeaderBytes = defaultMaxHeaderBytes
+	}
+	return srv
+}
+
+// limitBody 限制请求体大小, 命令声明的上限优先
+func (s *Svr) limitBody(c *gin.Context, cmd *bcmd.Cmd) error {
+	limit := cmd.GetMaxBodyBytes()
+	if limit <= 0 {
+		limit = s.maxBodyBytes
+	}
+	if limit <= 0 {
+		limit = defaultMaxBodyBytes
+	}
+	if c.Request.ContentLength > limit {
+		return bgin.NewHttpStatusErr(http.StatusRequestEntityTooLarge, "request body too large, limit %d bytes", limit)
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	return nil
+}
+
+// toReadBodyErr 读取请求体的错误转为 413 / 408
+func toReadBodyErr(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return bgin.NewHttpStatusErr(http.StatusRequestEntityTooLarge, "request body too large, limit %d bytes", maxBytesErr.Limit)
+	}
+	var netErr net.Error
+	if errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return bgin.NewHttpStatusErr(http.StatusRequestTimeout, "read request body timeout")
+	}
+	return err
+}
+
+// withTimeout 为请求设置处理超时, 截止时间通过 c.Request 的 context 透传到 GinUCtx
+func (s *Svr) withTimeout(c *gin.Context, cmd *bcmd.Cmd) context.CancelFunc {
+	timeout := cmd.GetTimeout()
+	if timeout <= 0 {
+		timeout = s.handlerTimeout
+	}
+	if timeout <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	c.Request = c.Request.WithContext(ctx)
+	return cancel
+}
+
+// callWithDeadline 在请求协程上执行 callF, 超时与客户端断开依赖 callF 响应 ctx 的取消
+// 返回时 callF 已结束, 调用方可以安全地释放幂等键等资源
+// 超时响应 504, 客户端断开响应 499
+func callWithDeadline(nCtx uctx.IUCtx, callF bcmd.HandlerFunc, req proto.Message) (proto.Message, error) {
+	rsp, err := callF(nCtx, req)
+	if err == nil {
+		return rsp, nil
+	}
+	if isDeadlineErr(err) || errors.Is(nCtx.Err(), context.DeadlineExceeded) {
+		return nil, newGatewayTimeoutErr()
+	}
+	if isCanceledErr(err) || errors.Is(nCtx.Err(), context.Canceled) {
+		return nil, newClientClosedErr()
+	}
+	return nil, err
+}
+
+func isDeadlineErr(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	return status.Code(err) == codes.DeadlineExceeded
+}
+
+func isCanceledErr(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return true
+	}
+	return status.Code(err) == codes.Canceled
+}
+
+func newClientClosedErr() error {
+	return bgin.NewHttpStatusErr(statusClientClosedRequest, "client closed request")
+}
+
+func newGatewayTimeoutErr() error {
+	return bgin.NewHttpStatusErr(http.StatusGatewayTimeout, "handler timeout")
+}
@@ -0,0 +1,39 @@
+package gate
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/oldbai555/micro/bcmd"
+	"github.com/oldbai555/micro/bconst"
+	"github.com/oldbai555/micro/bprometheus"
+	"net/http"
+	"strconv"
+)
+
+// withCmdMetrics 按命令统计请求数, 耗时与大小, method 取带版本前缀的路由模板
+func withCmdMetrics(cmd *bcmd.Cmd, h gin.HandlerFunc) gin.HandlerFunc {
+	method := cmd.VersionedPath()
+	return func(c *gin.Context) {
+		end := bprometheus.GateMetrics.Begin(method)
+		defer func() {
+			reqSize := -1
+			if c.Request.ContentLength >= 0 {
+				reqSize = int(c.Request.ContentLength)
+			}
+			end(cmdCode(c), reqSize, c.Writer.Size())
+		}()
+		h(c)
+	}
+}
+
+// cmdCode 优先取响应信封的 errcode, 没有信封时取 http 状态码
+func cmdCode(c *gin.Context) string {
+	if v, ok := c.Get(bconst.GinCtxErrCode); ok {
+		if errCode, ok := v.(int32); ok && errCode != 0 {
+			return strconv.Itoa(int(errCode))
+		}
+	}
+	if status := c.Writer.Status(); status >= http.StatusBadRequest {
+		return strconv.Itoa(status)
+	}
+	return bprometheus.CodeOK
+}
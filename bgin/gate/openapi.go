@@ -0,0 +1,298 @@
+package gate
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/micro/bcmd"
+	"github.com/oldbai555/micro/bconst"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"net/http"
+	"strings"
+)
+
+const (
+	openApiVersion     = "3.0.3"
+	openApiSidSecurity = "sid"
+	schemaRefPrefix    = "#/components/schemas/"
+)
+
+// 内置的文档页, swagger-ui 的资源由网关在文档页路径下输出, 见 registerSwaggerUi
+const openApiDocsHtml = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8"/>
+  <title>%s</title>
+  <link rel="stylesheet" href="%s/swagger-ui.css"/>
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="%s/swagger-ui-bundle.js"></script>
+<script>
+  window.onload = function () {
+    window.ui = SwaggerUIBundle({url: %q, dom_id: "#swagger-ui"});
+  };
+</script>
+</body>
+</html>`
+
+type openApiBuilder struct {
+	schemas map[string]interface{}
+}
+
+// BuildOpenApi 根据命令列表生成 OpenAPI 3 文档
+// 代理模式的命令需要传入 proxyMgr 才能拿到请求与响应的描述
+func BuildOpenApi(title string, cmdList []*bcmd.Cmd, proxyMgr *ProxyMgr) map[string]interface{} {
+	b := &openApiBuilder{schemas: map[string]interface{}{}}
+	b.schemas["Envelope"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"data":    map[string]interface{}{"type": "string", "description": "json encoded response"},
+			"errcode": map[string]interface{}{"type": "integer", "format": "int32"},
+			"errmsg":  map[string]interface{}{"type": "string"},
+			"hint":    map[string]interface{}{"type": "string"},
+		},
+	}
+
+	paths := map[string]interface{}{}
+	for _, cmd := range cmdList {
+		reqDesc, rspDesc := cmdMessageDesc(cmd, proxyMgr)
+		if reqDesc == nil || rspDesc == nil {
+			log.Warnf("skip open api of %s: message descriptor not found", cmd.Path)
+			continue
+		}
+		path := toOpenApiPath(cmd.VersionedPath())
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[path] = item
+		}
+		item[strings.ToLower(cmd.GetApiMethod())] = b.operation(cmd, reqDesc, rspDesc)
+	}
+
+	return map[string]interface{}{
+		"openapi": openApiVersion,
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": b.schemas,
+			"securitySchemes": map[string]interface{}{
+				openApiSidSecurity: map[string]interface{}{
+					"type": "apiKey",
+					"in":   "header",
+					"name": bconst.GinHeaderSid,
+				},
+			},
+		},
+	}
+}
+
+func (b *openApiBuilder) operation(cmd *bcmd.Cmd, reqDesc, rspDesc protoreflect.MessageDescriptor) map[string]interface{} {
+	reqRef := b.messageRef(reqDesc)
+	rspRef := b.messageRef(rspDesc)
+	op := map[string]interface{}{
+		"operationId": strings.Trim(strings.ReplaceAll(cmd.VersionedPath(), "/", "_"), "_"),
+		"summary":     cmd.FuncName,
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": fmt.Sprintf("%s: apijson 协议下 data 为 %s 的 json 串", bconst.PROTO_TYPE_API_JSON, rspDesc.FullName()),
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{
+							"allOf": []interface{}{
+								map[string]interface{}{"$ref": schemaRefPrefix + "Envelope"},
+							},
+							"x-data-schema": rspRef,
+						},
+					},
+					"application/x-protobuf": map[string]interface{}{
+						"schema": rspRef,
+					},
+				},
+			},
+		},
+	}
+	if cmd.Server != "" {
+		op["tags"] = []string{cmd.Server}
+	}
+	if cmd.IsDeprecated() {
+		op["deprecated"] = true
+	}
+	if cmd.IsUserAuthType() {
+		op["security"] = []interface{}{map[string]interface{}{openApiSidSecurity: []string{}}}
+	}
+
+	var params []interface{}
+	pathParamSet := map[string]bool{}
+	for _, seg := range strings.Split(cmd.Path, "/") {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			pathParamSet[seg[1:]] = true
+			params = append(params, map[string]interface{}{
+				"name": seg[1:], "in": "path", "required": true,
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+	}
+
+	// 与 bindReq 一致, 只有由 HttpRule 生成的命令绑定 query 参数
+	rule := cmd.HttpRule
+	if rule != nil && rule.Body != "*" {
+		fields := reqDesc.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			if fd.Message() != nil || fd.IsMap() || pathParamSet[string(fd.Name())] {
+				continue
+			}
+			params = append(params, map[string]interface{}{
+				"name": string(fd.Name()), "in": "query",
+				"schema": b.fieldSchema(fd),
+			})
+		}
+	}
+	if rule == nil || rule.Body != "" {
+		bodyRef := reqRef
+		if rule != nil && rule.Body != "*" {
+			if fd := reqDesc.Fields().ByName(protoreflect.Name(rule.Body)); fd != nil && fd.Message() != nil {
+				bodyRef = b.messageRef(fd.Message())
+			}
+		}
+		op["requestBody"] = map[string]interface{}{
+			"content": map[string]interface{}{
+				"application/json":       map[string]interface{}{"schema": bodyRef},
+				"application/x-protobuf": map[string]interface{}{"schema": bodyRef},
+			},
+		}
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	return op
+}
+
+func (b *openApiBuilder) messageRef(md protoreflect.MessageDescriptor) map[string]interface{} {
+	name := string(md.FullName())
+	if _, ok := b.schemas[name]; !ok {
+		// 先占位, 避免递归引用死循环
+		b.schemas[name] = map[string]interface{}{}
+		b.schemas[name] = b.messageSchema(md)
+	}
+	return map[string]interface{}{"$ref": schemaRefPrefix + name}
+}
+
+func (b *openApiBuilder) messageSchema(md protoreflect.MessageDescriptor) map[string]interface{} {
+	properties := map[string]interface{}{}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		var schema map[string]interface{}
+		switch {
+		case fd.IsMap():
+			schema = map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": b.fieldSchema(fd.MapValue()),
+			}
+		case fd.IsList():
+			schema = map[string]interface{}{
+				"type":  "array",
+				"items": b.fieldSchema(fd),
+			}
+		default:
+			schema = b.fieldSchema(fd)
+		}
+		// 网关按 proto 字段名输出 json
+		properties[string(fd.Name())] = schema
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+func (b *openApiBuilder) fieldSchema(fd protoreflect.FieldDescriptor) map[string]interface{} {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]interface{}{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]interface{}{"type": "integer", "format": "uint32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		// protojson 中 64 位整数以字符串输出
+		return map[string]interface{}{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]interface{}{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case protoreflect.StringKind:
+		return map[string]interface{}{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]interface{}{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		var enumList []string
+		values := fd.Enum().Values()
+		for i := 0; i < values.Len(); i++ {
+			enumList = append(enumList, string(values.Get(i).Name()))
+		}
+		return map[string]interface{}{"type": "string", "enum": enumList}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		switch fd.Message().FullName() {
+		case "google.protobuf.Timestamp":
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		case "google.protobuf.Duration":
+			return map[string]interface{}{"type": "string"}
+		case "google.protobuf.Struct", "google.protobuf.Any", "google.protobuf.Value":
+			return map[string]interface{}{"type": "object"}
+		}
+		return b.messageRef(fd.Message())
+	}
+	return map[string]interface{}{}
+}
+
+func cmdMessageDesc(cmd *bcmd.Cmd, proxyMgr *ProxyMgr) (req, rsp protoreflect.MessageDescriptor) {
+	if cmd.IsProxy() {
+		if proxyMgr == nil {
+			return nil, nil
+		}
+		md, err := proxyMgr.FindMethod(cmd.FullMethod)
+		if err != nil {
+			return nil, nil
+		}
+		return md.Input(), md.Output()
+	}
+	inv, err := cmd.GetInvoker()
+	if err != nil {
+		return nil, nil
+	}
+	return inv.NewReq().ProtoReflect().Descriptor(), inv.NewRsp().ProtoReflect().Descriptor()
+}
+
+// toOpenApiPath 将 gin 的 /users/:id 转成 /users/{id}
+func toOpenApiPath(path string) string {
+	segList := strings.Split(path, "/")
+	for i, seg := range segList {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			segList[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segList, "/")
+}
+
+func (s *Svr) registerOpenApi(router *gin.Engine) {
+	doc := BuildOpenApi(s.name, s.cmdList, s.proxyMgr)
+	router.GET(s.openApiPath, func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	})
+	if s.openApiDocsPath != "" {
+		assetsPath := strings.TrimRight(s.openApiDocsPath, "/") + "/assets"
+		page := fmt.Sprintf(openApiDocsHtml, s.name, assetsPath, assetsPath, s.openApiPath)
+		router.GET(s.openApiDocsPath, func(c *gin.Context) {
+			c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+		})
+		registerSwaggerUi(router, assetsPath)
+	}
+}
@@ -0,0 +1,178 @@
+package gate
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/oldbai555/micro/baudit"
+	"github.com/oldbai555/micro/bcache"
+	"github.com/oldbai555/micro/bcmd"
+	"github.com/oldbai555/micro/bgin"
+	"github.com/oldbai555/micro/blog"
+	"github.com/oldbai555/micro/bprometheus"
+	"github.com/oldbai555/micro/bredis"
+	"time"
+)
+
+type Option func(*Svr)
+
+// WithPolicyFunc 网关级别的权限策略, 对所有命令生效
+func WithPolicyFunc(list ...bcmd.PolicyFunc) Option {
+	return func(s *Svr) {
+		s.policyList = append(s.policyList, list...)
+	}
+}
+
+// WithProxyMgr 开启代理模式, 声明了 FullMethod 的命令将转发到远端服务
+func WithProxyMgr(p *ProxyMgr) Option {
+	return func(s *Svr) {
+		s.proxyMgr = p
+	}
+}
+
+// WithOpenApi 在 specPath 输出 OpenAPI 3 文档, docsPath 不为空时同时提供文档页
+func WithOpenApi(specPath, docsPath string) Option {
+	return func(s *Svr) {
+		s.openApiPath = specPath
+		s.openApiDocsPath = docsPath
+	}
+}
+
+// WithStreamHeartbeat 流式命令的心跳间隔, 默认 15s
+func WithStreamHeartbeat(interval time.Duration) Option {
+	return func(s *Svr) {
+		s.streamHeartbeat = interval
+	}
+}
+
+// WithReadHeaderTimeout 读取请求头的超时, 默认 10s
+func WithReadHeaderTimeout(timeout time.Duration) Option {
+	return func(s *Svr) {
+		s.readHeaderTimeout = timeout
+	}
+}
+
+// WithReadTimeout 读取整个请求 (含请求体) 的超时, 默认不限制
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(s *Svr) {
+		s.readTimeout = timeout
+	}
+}
+
+// WithWriteTimeout 写响应的超时, 默认不限制, 对 SSE 同样生效
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(s *Svr) {
+		s.writeTimeout = timeout
+	}
+}
+
+// WithIdleTimeout keep-alive 连接的空闲超时, 默认 60s
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(s *Svr) {
+		s.idleTimeout = timeout
+	}
+}
+
+// WithMaxHeaderBytes 请求头上限, 默认 1MB
+func WithMaxHeaderBytes(n int) Option {
+	return func(s *Svr) {
+		s.maxHeaderBytes = n
+	}
+}
+
+// WithMaxBodyBytes 请求体上限, 默认 4MB, 可被 bcmd.Cmd 的 MaxBodyBytes 覆盖
+func WithMaxBodyBytes(n int64) Option {
+	return func(s *Svr) {
+		s.maxBodyBytes = n
+	}
+}
+
+// WithHandlerTimeout 命令的处理超时, 默认不限制, 可被 bcmd.Cmd 的 Timeout 覆盖
+// 超时后通过 ctx 取消处理, 响应 504, 处理函数需要响应 ctx 的取消; 流式命令不受影响
+func WithHandlerTimeout(timeout time.Duration) Option {
+	return func(s *Svr) {
+		s.handlerTimeout = timeout
+	}
+}
+
+// WithCors 跨域配置, 默认为 bgin.DefaultCorsConfig()
+// WebSocket 跨域时只放行 AllowOrigins 中明确配置的来源, 见 bgin.NewWebSocketOriginChecker
+func WithCors(conf bgin.CorsConfig) Option {
+	return func(s *Svr) {
+		s.corsConf = &conf
+	}
+}
+
+// WithIdempotency 开启幂等键去重, 只对声明了 Idempotent 的命令生效
+// ttl 为幂等键的保留时间, 为 0 时默认 24h
+func WithIdempotency(rds *bredis.Group, ttl time.Duration) Option {
+	return func(s *Svr) {
+		if ttl <= 0 {
+			ttl = defaultIdempotencyTtl
+		}
+		s.idempotency = &idempotency{rds: rds, ttl: ttl, prefix: defaultIdempotencyPrefix}
+	}
+}
+
+// WithRespCache 开启响应缓存, 只对声明了缓存的命令生效
+// 业务代码持有同一个 bcache.Cache, 通过 InvalidateTag 失效
+func WithRespCache(c *bcache.Cache) Option {
+	return func(s *Svr) {
+		s.respCache = c
+	}
+}
+
+// WithAuditor 网关使用的审计日志, 默认为 baudit.Default()
+func WithAuditor(a *baudit.Auditor) Option {
+	return func(s *Svr) {
+		s.auditor = a
+	}
+}
+
+// WithMiddleware 网关级别的中间件, 在命令自身的中间件之外, 对代理命令同样生效
+func WithMiddleware(list ...bcmd.Middleware) Option {
+	return func(s *Svr) {
+		s.middlewares = append(s.middlewares, list...)
+	}
+}
+
+// WithCallerFunc 统计废弃命令调用方的方式, 默认取请求头 X-LB-CALLER
+func WithCallerFunc(f CallerFunc) Option {
+	return func(s *Svr) {
+		s.callerF = f
+	}
+}
+
+// WithCallerList 废弃命令统计的调用方白名单, 不在名单中的调用方计入 other
+// 未设置时最多统计 maxCallerValues 个不同的调用方, 超出的同样计入 other
+func WithCallerList(list ...string) Option {
+	return func(s *Svr) {
+		s.callers.allowMap = map[string]bool{}
+		for _, caller := range list {
+			s.callers.allowMap[caller] = true
+		}
+	}
+}
+
+// WithMetrics 在网关的 path 上输出监控指标, 为空时使用 /metrics
+// 如: gate.WithMetrics("", bprometheus.WithAllowIps("10.0.0.0/8"))
+func WithMetrics(path string, opts ...bprometheus.Option) Option {
+	return func(s *Svr) {
+		if path == "" {
+			path = bprometheus.PrometheusUrl
+		}
+		s.metricsPath = path
+		s.metricsOpts = opts
+	}
+}
+
+// WithLogAdmin 在网关的 path 上开放日志等级的管理接口, 为空时使用 /log/level, 见 blog.LevelHandler
+// 接口不经过命令的鉴权, handlers 在其全部方法之前执行, 用于加上鉴权, 为空时不挂载
+// 如: gate.WithLogAdmin("", gin.BasicAuth(gin.Accounts{"admin": "xxx"}))
+func WithLogAdmin(path string, handlers ...gin.HandlerFunc) Option {
+	return func(s *Svr) {
+		if path == "" {
+			path = blog.LevelUrl
+		}
+		s.logAdminPath = path
+		s.logAdminHandlers = handlers
+	}
+}
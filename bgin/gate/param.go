@@ -0,0 +1,150 @@
+package gate
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/oldbai555/lbtool/pkg/lberr"
+	"github.com/oldbai555/micro/bcmd"
+	"github.com/oldbai555/micro/bgin"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// bindReq 按命令的 HttpRule 解析请求, 未声明 HttpRule 的命令只读取请求体
+// body 为 * 时只绑定路径参数, 否则未被请求体占用的字段还可以来自 query
+func bindReq(c *gin.Context, cmd *bcmd.Cmd, body io.Reader, msg proto.Message, protocolType string) error {
+	handler := bgin.NewHandler(c)
+	rule := cmd.HttpRule
+	if rule == nil {
+		return handler.UnmarshalerByProtocol(io.NopCloser(body), msg, protocolType)
+	}
+
+	var bodyField protoreflect.FieldDescriptor
+	switch rule.Body {
+	case "":
+	case "*":
+		err := handler.UnmarshalerByProtocol(io.NopCloser(body), msg, protocolType)
+		if err != nil {
+			return err
+		}
+	default:
+		m := msg.ProtoReflect()
+		bodyField = m.Descriptor().Fields().ByName(protoreflect.Name(rule.Body))
+		if bodyField == nil {
+			return lberr.NewInvalidArg("http body field %s not found", rule.Body)
+		}
+		err := handler.UnmarshalerByProtocol(io.NopCloser(body), m.Mutable(bodyField).Message().Interface(), protocolType)
+		if err != nil {
+			return err
+		}
+	}
+	return bindParams(c, msg, rule.Body != "*", bodyField)
+}
+
+// bindParams 将路径参数与 query 参数写入请求的顶层字段, 字段名支持 proto 名与 json 名
+// bindQuery 为 false 时只绑定路径参数, skip 为请求体占用的字段, 不从 query 覆盖
+func bindParams(c *gin.Context, msg proto.Message, bindQuery bool, skip protoreflect.FieldDescriptor) error {
+	m := msg.ProtoReflect()
+	fields := m.Descriptor().Fields()
+	findField := func(name string) protoreflect.FieldDescriptor {
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = fields.ByJSONName(name)
+		}
+		return fd
+	}
+
+	for _, p := range c.Params {
+		fd := findField(p.Key)
+		if fd == nil || fd.IsList() || fd.IsMap() {
+			continue
+		}
+		// {name=**} 对应 gin 的 *name, 值以 / 开头
+		v, err := parseScalar(fd, strings.TrimPrefix(p.Value, "/"))
+		if err != nil {
+			return err
+		}
+		m.Set(fd, v)
+	}
+
+	if !bindQuery {
+		return nil
+	}
+	for key, valList := range c.Request.URL.Query() {
+		fd := findField(key)
+		if fd == nil || fd.IsMap() || fd == skip {
+			continue
+		}
+		for _, val := range valList {
+			v, err := parseScalar(fd, val)
+			if err != nil {
+				return err
+			}
+			if fd.IsList() {
+				m.Mutable(fd).List().Append(v)
+			} else {
+				m.Set(fd, v)
+			}
+		}
+	}
+	return nil
+}
+
+func parseScalar(fd protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	var err error
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte(s)), nil
+	case protoreflect.BoolKind:
+		var b bool
+		if b, err = strconv.ParseBool(s); err == nil {
+			return protoreflect.ValueOfBool(b), nil
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var i int64
+		if i, err = strconv.ParseInt(s, 10, 32); err == nil {
+			return protoreflect.ValueOfInt32(int32(i)), nil
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		var i int64
+		if i, err = strconv.ParseInt(s, 10, 64); err == nil {
+			return protoreflect.ValueOfInt64(i), nil
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		var u uint64
+		if u, err = strconv.ParseUint(s, 10, 32); err == nil {
+			return protoreflect.ValueOfUint32(uint32(u)), nil
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		var u uint64
+		if u, err = strconv.ParseUint(s, 10, 64); err == nil {
+			return protoreflect.ValueOfUint64(u), nil
+		}
+	case protoreflect.FloatKind:
+		var f float64
+		if f, err = strconv.ParseFloat(s, 32); err == nil {
+			return protoreflect.ValueOfFloat32(float32(f)), nil
+		}
+	case protoreflect.DoubleKind:
+		var f float64
+		if f, err = strconv.ParseFloat(s, 64); err == nil {
+			return protoreflect.ValueOfFloat64(f), nil
+		}
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		var i int64
+		if i, err = strconv.ParseInt(s, 10, 32); err == nil {
+			return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), nil
+		}
+	default:
+		err = fmt.Errorf("unsupported kind %s", fd.Kind())
+	}
+	return protoreflect.Value{}, lberr.NewInvalidArg("invalid param %s: %v", fd.Name(), err)
+}
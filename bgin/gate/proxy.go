@@ -0,0 +1,182 @@
+package gate
+
+import (
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/lbtool/pkg/lberr"
+	"github.com/oldbai555/micro/bcmd"
+	"github.com/oldbai555/micro/brpc/discover"
+	"github.com/oldbai555/micro/uctx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// lberr.Error 经过 grpc 传输后的 message 格式, 形如 Error[code]:[message]\n Stack:\n...
+// message 中可能含有 ], 取 Stack 之前的最后一个 ]
+var rpcErrReg = regexp.MustCompile(`(?s)^Error\[(-?\d+)\]:\[(.*)\](?:\n Stack:|$)`)
+
+// ProxyMgr 网关代理模式
+// 根据描述集动态构造请求与响应, 通过 discover.V2 的连接转发到远端服务, 网关不需要链接服务实现
+type ProxyMgr struct {
+	files *protoregistry.Files
+
+	lock    sync.Mutex
+	connMap map[string]*grpc.ClientConn
+	dialMap map[string]*dialCall // 正在建立的连接, 同一个服务只拨号一次
+}
+
+// dialCall 一次拨号, 结束时关闭 done
+type dialCall struct {
+	done chan struct{}
+	conn *grpc.ClientConn
+	err  error
+}
+
+// NewProxyMgr 读取 protoc --include_imports --descriptor_set_out 生成的描述集
+func NewProxyMgr(descSetPath string) (*ProxyMgr, error) {
+	buf, err := os.ReadFile(descSetPath)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, err
+	}
+	var set descriptorpb.FileDescriptorSet
+	err = proto.Unmarshal(buf, &set)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, err
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, err
+	}
+	return NewProxyMgrFromFiles(files), nil
+}
+
+func NewProxyMgrFromFiles(files *protoregistry.Files) *ProxyMgr {
+	return &ProxyMgr{
+		files:   files,
+		connMap: make(map[string]*grpc.ClientConn),
+		dialMap: make(map[string]*dialCall),
+	}
+}
+
+// FindMethod fullMethod 格式为 /package.Service/Method
+func (p *ProxyMgr) FindMethod(fullMethod string) (protoreflect.MethodDescriptor, error) {
+	name := strings.Replace(strings.TrimPrefix(fullMethod, "/"), "/", ".", 1)
+	d, err := p.files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, lberr.NewInvalidArg("not found method %s", fullMethod)
+	}
+	md, ok := d.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, lberr.NewInvalidArg("%s is not method", fullMethod)
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, lberr.NewInvalidArg("streaming method %s not supported", fullMethod)
+	}
+	return md, nil
+}
+
+// getConn 拨号时不持有锁, 一个服务连接慢不会阻塞其他服务的调用
+func (p *ProxyMgr) getConn(server string) (*grpc.ClientConn, error) {
+	p.lock.Lock()
+	if conn, ok := p.connMap[server]; ok {
+		p.lock.Unlock()
+		return conn, nil
+	}
+	if call, ok := p.dialMap[server]; ok {
+		p.lock.Unlock()
+		<-call.done
+		return call.conn, call.err
+	}
+	call := &dialCall{done: make(chan struct{})}
+	p.dialMap[server] = call
+	p.lock.Unlock()
+
+	call.err = discover.V2(server, func(c *grpc.ClientConn) {
+		call.conn = c
+	})
+
+	p.lock.Lock()
+	delete(p.dialMap, server)
+	if call.err == nil {
+		p.connMap[server] = call.conn
+	}
+	p.lock.Unlock()
+	close(call.done)
+
+	if call.err != nil {
+		log.Errorf("err:%v", call.err)
+		return nil, call.err
+	}
+	return call.conn, nil
+}
+
+// Invoke 调用远端方法, uctx 的身份字段由连接上的 middleware.UCtxClient 通过 metadata 透传
+func (p *ProxyMgr) Invoke(nCtx uctx.IUCtx, server string, md protoreflect.MethodDescriptor, req proto.Message) (proto.Message, error) {
+	conn, err := p.getConn(server)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, err
+	}
+
+	fullMethod := "/" + string(md.Parent().FullName()) + "/" + string(md.Name())
+	rsp := dynamicpb.NewMessage(md.Output())
+	err = conn.Invoke(nCtx, fullMethod, req, rsp)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, fromRpcErr(err)
+	}
+	return rsp, nil
+}
+
+// fromRpcErr 还原远端返回的 lberr.Error, 保证与进程内调用的错误码一致
+func fromRpcErr(err error) error {
+	s, ok := status.FromError(err)
+	if !ok || s.Code() != codes.Unknown {
+		return err
+	}
+	match := rpcErrReg.FindStringSubmatch(s.Message())
+	if len(match) != 3 {
+		return err
+	}
+	code, parseErr := strconv.ParseInt(match[1], 10, 32)
+	if parseErr != nil {
+		return err
+	}
+	return lberr.NewErr(int32(code), match[2])
+}
+
+// newProxyCall 代理的请求为 dynamicpb, 没有参数校验, 由远端服务校验
+func (s *Svr) newProxyCall(cmd *bcmd.Cmd) (newReqF func() proto.Message, validateF func(proto.Message) error, callF bcmd.HandlerFunc) {
+	if s.proxyMgr == nil {
+		panic("proxy cmd " + cmd.Path + " requires gate.WithProxyMgr")
+	}
+	md, err := s.proxyMgr.FindMethod(cmd.FullMethod)
+	if err != nil {
+		panic(err)
+	}
+	newReqF = func() proto.Message {
+		return dynamicpb.NewMessage(md.Input())
+	}
+	validateF = func(proto.Message) error {
+		return nil
+	}
+	callF = bcmd.Chain(cmd, func(nCtx uctx.IUCtx, req proto.Message) (proto.Message, error) {
+		return s.proxyMgr.Invoke(nCtx, cmd.Server, md, req)
+	}, s.middlewares...)
+	return
+}
@@ -0,0 +1,81 @@
+package gate
+
+import (
+	"context"
+	"github.com/oldbai555/micro/bcmd"
+	"github.com/oldbai555/micro/bconst"
+	"github.com/oldbai555/micro/brpc/middleware"
+	"github.com/oldbai555/micro/uctx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"net"
+	"testing"
+)
+
+func TestProxyMgrInvokeMetadata(t *testing.T) {
+	mdCh := make(chan metadata.MD, 1)
+	srv := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		mdCh <- md
+		return handler(ctx, req)
+	}))
+	grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	defer srv.Stop()
+
+	// 与 discover.V2 使用相同的拨号参数
+	conn, err := grpc.Dial(l.Addr().String(), middleware.RoundRobinDialOpts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	p := NewProxyMgrFromFiles(protoregistry.GlobalFiles)
+	p.connMap["health"] = conn
+	methodDesc, err := p.FindMethod("/grpc.health.v1.Health/Check")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nCtx := uctx.NewBaseUCtx()
+	nCtx.SetTraceId("trace-1")
+	nCtx.SetDeviceId("device-1")
+	nCtx.SetSid("sid-1")
+	nCtx.SetAuthType(bcmd.AuthTypeUser)
+	nCtx.SetLocale("zh-CN")
+	nCtx.SetClientIp("10.0.0.1")
+	nCtx.SetUserId(1)
+	nCtx.SetCorpId(2)
+	nCtx.SetRoles([]string{"admin"})
+
+	_, err = p.Invoke(nCtx, "health", methodDesc, dynamicpb.NewMessage(methodDesc.Input()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := <-mdCh
+	for _, key := range []string{
+		bconst.GrpcHeaderTraceId,
+		bconst.GrpcHeaderDeviceId,
+		bconst.GrpcHeaderSid,
+		bconst.GrpcHeaderAuthType,
+		bconst.GrpcHeaderLocale,
+		bconst.GrpcHeaderClientIp,
+		bconst.GrpcHeaderUserId,
+		bconst.GrpcHeaderCorpId,
+		bconst.GrpcHeaderRoles,
+	} {
+		if got := md.Get(key); len(got) != 1 {
+			t.Errorf("metadata %s: got %v, want exactly one value", key, got)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package gate
+
+import (
+	"fmt"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/micro/bcmd"
+	"github.com/oldbai555/micro/bconst"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+type routeBuilder struct {
+	prefix          string
+	defaultAuthType string
+	pathF           func(service, method string) string
+}
+
+type RouteOption func(*routeBuilder)
+
+// WithRoutePrefix 生成的路径统一加前缀
+func WithRoutePrefix(prefix string) RouteOption {
+	return func(b *routeBuilder) {
+		b.prefix = strings.TrimRight(prefix, "/")
+	}
+}
+
+// WithRouteDefaultAuthType 方法上没有声明鉴权类型时使用的默认值
+func WithRouteDefaultAuthType(authType string) RouteOption {
+	return func(b *routeBuilder) {
+		b.defaultAuthType = authType
+	}
+}
+
+// WithRoutePathFunc 自定义路径约定, 默认 /{service}/{method}
+func WithRoutePathFunc(f func(service, method string) string) RouteOption {
+	return func(b *routeBuilder) {
+		b.pathF = f
+	}
+}
+
+func newRouteBuilder(opts ...RouteOption) *routeBuilder {
+	b := &routeBuilder{
+		pathF: func(service, method string) string {
+			return fmt.Sprintf("/%s/%s", service, method)
+		},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// CmdListFromServiceDesc 根据 grpc.ServiceDesc 生成网关命令
+// srv 为服务的实现, 方法上的 google.api.http 与 lb.api.auth_type 注解会被读取
+func CmdListFromServiceDesc(desc *grpc.ServiceDesc, srv interface{}, opts ...RouteOption) []*bcmd.Cmd {
+	b := newRouteBuilder(opts...)
+
+	var sd protoreflect.ServiceDescriptor
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(desc.ServiceName))
+	if err != nil {
+		log.Warnf("not found service descriptor %s, use path convention only", desc.ServiceName)
+	} else {
+		sd, _ = d.(protoreflect.ServiceDescriptor)
+	}
+
+	srvV := reflect.ValueOf(srv)
+	var cmdList []*bcmd.Cmd
+	for _, m := range desc.Methods {
+		fn := srvV.MethodByName(m.MethodName)
+		if !fn.IsValid() {
+			panic(fmt.Sprintf("service %s not implement method %s", desc.ServiceName, m.MethodName))
+		}
+		var md protoreflect.MethodDescriptor
+		if sd != nil {
+			md = sd.Methods().ByName(protoreflect.Name(m.MethodName))
+		}
+		cmdList = append(cmdList, b.newCmd(desc.ServiceName, m.MethodName, md, fn.Interface()))
+	}
+
+	// 服务端流式方法以 SSE / WebSocket 暴露, 双向与客户端流式不支持
+	for _, st := range desc.Streams {
+		if !st.ServerStreams || st.ClientStreams {
+			continue
+		}
+		var md protoreflect.MethodDescriptor
+		if sd != nil {
+			md = sd.Methods().ByName(protoreflect.Name(st.StreamName))
+		}
+		cmdList = append(cmdList, b.newCmd(desc.ServiceName, st.StreamName, md, bcmd.NewStreamFunc(srv, st.Handler)))
+	}
+	return cmdList
+}
+
+// CmdListFromServiceDescriptor 根据 protoreflect 服务描述生成网关命令, 跳过流式方法
+func CmdListFromServiceDescriptor(sd protoreflect.ServiceDescriptor, srv interface{}, opts ...RouteOption) []*bcmd.Cmd {
+	b := newRouteBuilder(opts...)
+
+	srvV := reflect.ValueOf(srv)
+	var cmdList []*bcmd.Cmd
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		if md.IsStreamingClient() || md.IsStreamingServer() {
+			continue
+		}
+		fn := srvV.MethodByName(string(md.Name()))
+		if !fn.IsValid() {
+			panic(fmt.Sprintf("service %s not implement method %s", sd.FullName(), md.Name()))
+		}
+		cmdList = append(cmdList, b.newCmd(string(sd.FullName()), string(md.Name()), md, fn.Interface()))
+	}
+	return cmdList
+}
+
+func (b *routeBuilder) newCmd(service, method string, md protoreflect.MethodDescriptor, fn interface{}) *bcmd.Cmd {
+	cmd := &bcmd.Cmd{
+		Server:    service,
+		Path:      b.prefix + b.pathF(service, method),
+		FuncName:  method,
+		OptionMap: map[string]string{},
+		GRpcFunc:  fn,
+	}
+	if b.defaultAuthType != "" {
+		cmd.OptionMap[bcmd.AuthType] = b.defaultAuthType
+	}
+	if md == nil {
+		return cmd
+	}
+
+	opts := md.Options()
+	if opts == nil {
+		return cmd
+	}
+
+	if rule, ok := proto.GetExtension(opts, annotations.E_Http).(*annotations.HttpRule); ok && rule != nil {
+		if len(rule.GetAdditionalBindings()) > 0 {
+			panic(fmt.Sprintf("method %s.%s: additional_bindings is not supported", service, method))
+		}
+		httpMethod, path := parseHttpRule(rule)
+		if path != "" {
+			checkHttpBody(service, method, md, rule.GetBody())
+			cmd.Path = b.prefix + toGinPath(path)
+			cmd.OptionMap[bcmd.ApiMethod] = httpMethod
+			cmd.HttpRule = &bcmd.HttpRule{Body: rule.GetBody()}
+		}
+	}
+
+	if authType := getStringOption(opts, bconst.ProtoOptionAuthType); authType != "" {
+		cmd.OptionMap[bcmd.AuthType] = authType
+	}
+	return cmd
+}
+
+func parseHttpRule(rule *annotations.HttpRule) (method, path string) {
+	switch {
+	case rule.GetGet() != "":
+		return http.MethodGet, rule.GetGet()
+	case rule.GetPost() != "":
+		return http.MethodPost, rule.GetPost()
+	case rule.GetPut() != "":
+		return http.MethodPut, rule.GetPut()
+	case rule.GetDelete() != "":
+		return http.MethodDelete, rule.GetDelete()
+	case rule.GetPatch() != "":
+		return http.MethodPatch, rule.GetPatch()
+	case rule.GetCustom() != nil:
+		return strings.ToUpper(rule.GetCustom().GetKind()), rule.GetCustom().GetPath()
+	}
+	return "", ""
+}
+
+// checkHttpBody body 只支持 *, 空或请求中非 repeated 的 message 字段
+func checkHttpBody(service, method string, md protoreflect.MethodDescriptor, body string) {
+	if body == "" || body == "*" {
+		return
+	}
+	fd := md.Input().Fields().ByName(protoreflect.Name(body))
+	if fd == nil || fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+		panic(fmt.Sprintf("method %s.%s: http body %q must be a message field of %s", service, method, body, md.Input().FullName()))
+	}
+}
+
+// toGinPath 将 /v1/users/{id} 转成 /v1/users/:id, {name=*} 转成 :name, {name=**} 转成 *name
+// 变量按 {...} 整体解析后再替换, 其他模式 (如 {name=shelves/*}) 原样保留, 由 checkGinPath 拒绝
+func toGinPath(path string) string {
+	var sb strings.Builder
+	for {
+		start := strings.Index(path, "{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(path[start:], "}")
+		if end < 0 {
+			break
+		}
+		end += start
+		sb.WriteString(path[:start])
+		sb.WriteString(toGinParam(path[start : end+1]))
+		path = path[end+1:]
+	}
+	sb.WriteString(path)
+	return sb.String()
+}
+
+func toGinParam(v string) string {
+	name, pattern := v[1:len(v)-1], ""
+	if idx := strings.Index(name, "="); idx >= 0 {
+		name, pattern = name[:idx], name[idx+1:]
+	}
+	switch pattern {
+	case "", "*":
+		return ":" + name
+	case "**":
+		return "*" + name
+	}
+	return v
+}
+
+// checkGinPath 路径变量只支持 {name}, {name=*} 与作为最后一段的 {name=**}
+func checkGinPath(path string) error {
+	segList := strings.Split(path, "/")
+	for i, seg := range segList {
+		if strings.ContainsAny(seg, "{}") {
+			return fmt.Errorf("unsupported path variable in %s, only {name}, {name=*} and {name=**} are supported", path)
+		}
+		if strings.HasPrefix(seg, "*") && i != len(segList)-1 {
+			return fmt.Errorf("path variable %s must be the last segment of %s", seg, path)
+		}
+	}
+	return nil
+}
+
+// getStringOption 按扩展全名读取字符串类型的 option, 扩展需已在全局注册
+func getStringOption(opts proto.Message, fullName string) string {
+	var val string
+	opts.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.IsExtension() && string(fd.FullName()) == fullName && fd.Kind() == protoreflect.StringKind {
+			val = v.String()
+			return false
+		}
+		return true
+	})
+	return val
+}
@@ -0,0 +1,42 @@
+package gate
+
+import (
+	"context"
+	"github.com/oldbai555/micro/bcmd"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"strings"
+	"testing"
+)
+
+func TestToGinPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"/v1/users/{id}":              "/v1/users/:id",
+		"/v1/users/{id=*}/books":      "/v1/users/:id/books",
+		"/v1/files/{name=**}":         "/v1/files/*name",
+		"/v1/{name=shelves/*}":        "/v1/{name=shelves/*}",
+		"/v1/{parent=shelves/*}/book": "/v1/{parent=shelves/*}/book",
+	} {
+		if got := toGinPath(path); got != want {
+			t.Errorf("%s: got %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestCheckCmdListPathTemplate(t *testing.T) {
+	fn := func(_ context.Context, req *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		return req, nil
+	}
+	for _, path := range []string{"/v1/{name=shelves/*}", "/v1/files/{name=**}/meta"} {
+		cmd := bcmd.NewCmd(toGinPath(path), fn).WithAuthType(bcmd.AuthTypePublic)
+		cmd.FuncName = "GetShelf"
+		func() {
+			defer func() {
+				err := recover()
+				if err == nil || !strings.Contains(err.(string), "GetShelf") {
+					t.Errorf("%s: got %v, want panic naming the cmd", path, err)
+				}
+			}()
+			CheckCmdList([]*bcmd.Cmd{cmd})
+		}()
+	}
+}
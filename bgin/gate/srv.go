@@ -9,15 +9,19 @@ import (
 	"github.com/oldbai555/lbtool/log"
 	"github.com/oldbai555/lbtool/pkg/lberr"
 	"github.com/oldbai555/lbtool/pkg/signal"
-	"github.com/oldbai555/lbtool/utils"
+	"github.com/oldbai555/micro/baudit"
+	"github.com/oldbai555/micro/bcache"
 	"github.com/oldbai555/micro/bcmd"
 	"github.com/oldbai555/micro/bconst"
 	"github.com/oldbai555/micro/bgin"
 	"github.com/oldbai555/micro/blimiter"
+	"github.com/oldbai555/micro/blog"
+	"github.com/oldbai555/micro/bprometheus"
 	"google.golang.org/protobuf/proto"
 	"net/http"
 	"os"
-	"reflect"
+	"strings"
+	"time"
 )
 
 type CheckAuthFunc func(ctx context.Context, sid string) (interface{}, error)
@@ -27,42 +31,57 @@ type Svr struct {
 	port          uint32
 	cmdList       []*bcmd.Cmd
 	checkAuthFunc CheckAuthFunc
+	policyList    []bcmd.PolicyFunc
+	proxyMgr      *ProxyMgr
+
+	openApiPath     string
+	openApiDocsPath string
+
+	metricsPath string
+	metricsOpts []bprometheus.Option
+
+	logAdminPath     string
+	logAdminHandlers []gin.HandlerFunc
+
+	streamHeartbeat time.Duration
+
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+	maxBodyBytes      int64
+	handlerTimeout    time.Duration
+
+	corsConf  *bgin.CorsConfig
+	wsOriginF func(r *http.Request) bool
+
+	idempotency *idempotency
+	respCache   *bcache.Cache
+	auditor     *baudit.Auditor
+	middlewares []bcmd.Middleware
+	callerF     CallerFunc
+	callers     callerLabels
 
 	httpSrv *http.Server
 }
 
-func NewSvr(name string, port uint32, cmdList []*bcmd.Cmd, checkAuthFunc CheckAuthFunc) *Svr {
-	return &Svr{name: name, port: port, cmdList: cmdList, checkAuthFunc: checkAuthFunc}
+func NewSvr(name string, port uint32, cmdList []*bcmd.Cmd, checkAuthFunc CheckAuthFunc, opts ...Option) *Svr {
+	s := &Svr{name: name, port: port, cmdList: cmdList, checkAuthFunc: checkAuthFunc}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Svr) StartSrv(ctx context.Context) error {
 	gin.DefaultWriter = log.GetWriter()
+	blog.SetService(s.name)
 	gin.DebugPrintRouteFunc = func(httpMethod, absolutePath, handlerName string, nuHandlers int) {
 		log.Infof("%-6s %-25s --> %s (%d handlers)", httpMethod, absolutePath, handlerName, nuHandlers)
 	}
-	router := gin.New()
 
-	// Create a limiter struct.
-	limiter := tollbooth.NewLimiter(blimiter.Max, blimiter.DefaultExpiredAbleOptions())
-
-	router.Use(
-		gin.Recovery(),
-		gin.LoggerWithFormatter(bgin.NewLogFormatter(s.name)),
-		bgin.Cors(),
-		bgin.RegisterUuidTrace(),
-		tollbooth_gin.LimitHandler(limiter),
-	)
-
-	CheckCmdList(s.cmdList)
-
-	for _, cmd := range s.cmdList {
-		registerCmd(router, cmd, s.checkAuthFunc)
-	}
-
-	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", s.port),
-		Handler: router,
-	}
+	srv := s.newHttpSrv(s.newRouter())
 
 	s.httpSrv = srv
 
@@ -87,6 +106,58 @@ func (s *Svr) StartSrv(ctx context.Context) error {
 	return nil
 }
 
+// newRouter 网关的路由, 包括命令与监控等管理接口
+func (s *Svr) newRouter() *gin.Engine {
+	router := gin.New()
+
+	// Create a limiter struct.
+	limiter := tollbooth.NewLimiter(blimiter.Max, blimiter.DefaultExpiredAbleOptions())
+
+	corsConf := bgin.DefaultCorsConfig()
+	if s.corsConf != nil {
+		corsConf = *s.corsConf
+	}
+	s.wsOriginF = bgin.NewWebSocketOriginChecker(corsConf)
+
+	router.Use(
+		gin.Recovery(),
+		bgin.LoggerWithBlog(),
+		bgin.CorsWithConfig(corsConf),
+		bgin.RegisterUuidTrace(),
+		tollbooth_gin.LimitHandler(limiter),
+	)
+
+	CheckCmdList(s.cmdList)
+
+	s.registerCmdList(router)
+
+	if s.openApiPath != "" {
+		s.registerOpenApi(router)
+	}
+
+	if s.metricsPath != "" {
+		router.GET(s.metricsPath, gin.WrapH(bprometheus.Handler(s.metricsOpts...)))
+	}
+
+	if s.logAdminPath != "" {
+		s.registerLogAdmin(router)
+	}
+
+	return router
+}
+
+// registerLogAdmin 挂载 blog.LevelHandler 支持的全部方法, 均经过同一组鉴权, 没有鉴权的 handler 时不挂载
+func (s *Svr) registerLogAdmin(router gin.IRouter) {
+	if len(s.logAdminHandlers) == 0 {
+		log.Errorf("log admin %s requires auth handlers, skip", s.logAdminPath)
+		return
+	}
+	handlers := append(append([]gin.HandlerFunc{}, s.logAdminHandlers...), gin.WrapH(blog.LevelHandler()))
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete} {
+		router.Handle(method, s.logAdminPath, handlers...)
+	}
+}
+
 func (s *Svr) Stop() {
 	if s.httpSrv == nil {
 		return
@@ -98,128 +169,266 @@ func (s *Svr) Stop() {
 	}
 }
 
+// CheckCmdList 启动时校验命令的声明, 并检查重复的路由
 func CheckCmdList(cmdList []*bcmd.Cmd) {
+	routeMap := map[string]string{}
 	for _, cmd := range cmdList {
-		h := cmd.GRpcFunc
-		v := reflect.ValueOf(h)
-		t := v.Type()
-		if !t.In(0).Implements(reflect.TypeOf((*context.Context)(nil)).Elem()) {
-			panic("XX(context.Context, proto.Message)(proto.Message, error): first in arg must be context.Context")
+		err := cmd.Validate()
+		if err != nil {
+			panic(err)
 		}
-		if !t.In(1).Implements(reflect.TypeOf((*proto.Message)(nil)).Elem()) {
-			panic("XX(context.Context, proto.Message)(proto.Message, error): first out arg must be proto.Message")
+		err = checkGinPath(cmd.Path)
+		if err != nil {
+			panic(fmt.Sprintf("cmd %s: %v", cmd.FuncName, err))
 		}
-		if !t.Out(0).Implements(reflect.TypeOf((*proto.Message)(nil)).Elem()) {
-			panic("XX(context.Context, proto.Message)(proto.Message, error): first out arg must be proto.Message")
+
+		methodList := []string{cmd.GetApiMethod()}
+		if cmd.IsStream() {
+			methodList = []string{http.MethodGet, http.MethodPost}
 		}
-		if t.Out(1).String() != "error" {
-			panic("XX(context.Context, proto.Message)(proto.Message, error): second out arg must be error")
+		for _, method := range methodList {
+			// 同一路径的不同版本按 Accept-Version 分发, 不视为重复
+			// 以带版本前缀的路径判断, 未声明版本的 /v2/x 与声明了 v2 的 /x 注册的是同一个路由
+			route := method + " " + cmd.VersionedPath()
+			if funcName, ok := routeMap[route]; ok {
+				panic(fmt.Sprintf("duplicate route %s %s: %s and %s", method, cmd.VersionedPath(), funcName, cmd.FuncName))
+			}
+			routeMap[route] = cmd.FuncName
+		}
+
+		if cmd.IsStream() {
+			continue
+		}
+		if cmd.IsProxy() {
+			if cmd.Server == "" {
+				panic(fmt.Sprintf("proxy cmd %s: server is empty", cmd.Path))
+			}
+			continue
+		}
+		// 解析结果缓存在 cmd 上, newLocalCall 直接复用
+		_, err = cmd.GetInvoker()
+		if err != nil {
+			panic(err)
 		}
 	}
 }
 
-func registerCmd(router *gin.Engine, cmd *bcmd.Cmd, checkF CheckAuthFunc) {
-	router.POST(cmd.Path, func(c *gin.Context) {
-		handler := bgin.NewHandler(c)
+// newLocalCall 进程内通过 bcmd.Invoker 调用 GRpcFunc, 网关的中间件包裹在命令自身的中间件外层
+// 与 GinPost 一致, 参数校验由 validateF 在登录态校验之前完成, callF 不再校验
+func (s *Svr) newLocalCall(cmd *bcmd.Cmd) (newReqF func() proto.Message, validateF func(proto.Message) error, callF bcmd.HandlerFunc) {
+	inv, err := cmd.GetInvoker()
+	if err != nil {
+		panic(err)
+	}
+	inv = inv.Wrap(s.middlewares...)
+	return inv.NewReq, inv.Validate, inv.Call
+}
 
-		nCtx := NewGinUCtx(c)
+// withCmdLimit 命令声明了 RateLimit 时单独限流
+// 同一路径可能按版本分发, 因此直接包裹 handler 而不是作为 gin 的中间件
+func withCmdLimit(cmd *bcmd.Cmd, h gin.HandlerFunc) gin.HandlerFunc {
+	if cmd.RateLimit <= 0 {
+		return h
+	}
+	limiter := tollbooth.NewLimiter(cmd.RateLimit, blimiter.DefaultExpiredAbleOptions())
+	return func(c *gin.Context) {
+		httpErr := tollbooth.LimitByRequest(limiter, c.Writer, c.Request)
+		if httpErr != nil {
+			c.Data(httpErr.StatusCode, limiter.GetMessageContentType(), []byte(httpErr.Message))
+			c.Abort()
+			return
+		}
+		h(c)
+	}
+}
 
-		// 组装 nCtx
-		{
-			val := c.GetHeader(bconst.ProtocolType)
-			if val != "" {
-				nCtx.SetProtocolType(val)
-			} else {
-				nCtx.SetProtocolType(bconst.PROTO_TYPE_PROTO3) // 默认pb
-			}
+func (s *Svr) getAuditor() *baudit.Auditor {
+	if s.auditor != nil {
+		return s.auditor
+	}
+	return baudit.Default()
+}
 
-			val = c.GetHeader(bconst.GinHeaderTraceId)
-			if val != "" {
-				val = fmt.Sprintf("%s.%s", val, utils.GenRandomStr())
-			} else {
-				val = utils.GenRandomStr()
-			}
-			nCtx.SetTraceId(val)
-			log.SetLogHint(val)
+// newUCtx 根据请求头组装 nCtx
+func (s *Svr) newUCtx(c *gin.Context, cmd *bcmd.Cmd) *GinUCtx {
+	c.Request = c.Request.WithContext(blog.WithMethod(c.Request.Context(), cmd.Path))
+	nCtx := NewGinUCtx(c)
 
-			val = c.GetHeader(bconst.GinHeaderDeviceId)
-			if val != "" {
-				nCtx.SetDeviceId(val)
-			}
+	val := c.GetHeader(bconst.ProtocolType)
+	if val != "" {
+		nCtx.SetProtocolType(val)
+	} else {
+		nCtx.SetProtocolType(bconst.PROTO_TYPE_PROTO3) // 默认pb
+	}
 
-			val = c.GetHeader(bconst.GinHeaderSid)
-			if val != "" {
-				nCtx.SetSid(val)
-			}
+	nCtx.SetTraceId(bgin.TraceId(c))
 
-			val = c.GetHeader(bconst.GinHeaderAuthType)
-			if val != "" {
-				nCtx.SetAuthType(val)
-			} else {
-				nCtx.SetAuthType(cmd.GetAuthType())
-			}
+	val = c.GetHeader(bconst.GinHeaderDeviceId)
+	if val != "" {
+		nCtx.SetDeviceId(val)
+	}
 
-			// 需要校验
-			if cmd.IsUserAuthType() {
-				if checkF == nil {
-					panic("check auth func is nil")
-				}
-				info, err := checkF(nCtx, nCtx.Sid())
-				if err != nil {
-					log.Errorf("err:%v", err)
-					handler.Error(err)
-					return
-				}
-				nCtx.SetExtInfo(info)
-			}
-		}
+	val = c.GetHeader(bconst.GinHeaderSid)
+	if val != "" {
+		nCtx.SetSid(val)
+	}
 
-		// call
-		h := cmd.GRpcFunc
-		v := reflect.ValueOf(h)
-		t := v.Type()
+	val = c.GetHeader(bconst.GinHeaderAuthType)
+	if val != "" {
+		nCtx.SetAuthType(val)
+	} else {
+		nCtx.SetAuthType(cmd.GetAuthType())
+	}
+
+	nCtx.SetLocale(requestLocale(c))
+	nCtx.SetClientIp(c.ClientIP())
+	return nCtx
+}
+
+// requestLocale 优先取 X-LB-LOCALE, 否则取 Accept-Language 的第一项
+func requestLocale(c *gin.Context) string {
+	val := c.GetHeader(bconst.GinHeaderLocale)
+	if val != "" {
+		return val
+	}
+	val = c.GetHeader(bconst.HeaderAcceptLanguage)
+	if i := strings.IndexAny(val, ",;"); i >= 0 {
+		val = val[:i]
+	}
+	return strings.TrimSpace(val)
+}
+
+// checkAuth 按命令的鉴权类型完成鉴权, 并写入 ExtInfo
+func (s *Svr) checkAuth(nCtx *GinUCtx, cmd *bcmd.Cmd) error {
+	if !cmd.IsUserAuthType() {
+		return nil
+	}
+	if s.checkAuthFunc == nil {
+		panic("check auth func is nil")
+	}
+	info, err := s.checkAuthFunc(nCtx, nCtx.Sid())
+	if err != nil {
+		return err
+	}
+	nCtx.SetExtInfo(info)
+	return nil
+}
+
+func (s *Svr) newCmdHandler(cmd *bcmd.Cmd) gin.HandlerFunc {
+	if cmd.IsStream() {
+		return withCmdMetrics(cmd, withCmdTrace(cmd, s.newStreamHandler(cmd)))
+	}
+
+	var newReqF func() proto.Message
+	var validateF func(proto.Message) error
+	var callF bcmd.HandlerFunc
+	if cmd.IsProxy() {
+		newReqF, validateF, callF = s.newProxyCall(cmd)
+	} else {
+		newReqF, validateF, callF = s.newLocalCall(cmd)
+	}
+
+	return withCmdMetrics(cmd, withCmdTrace(cmd, withCmdLimit(cmd, func(c *gin.Context) {
+		handler := bgin.NewHandler(c)
+
+		cancel := s.withTimeout(c, cmd)
+		defer cancel()
+
+		nCtx := s.newUCtx(c, cmd)
+
+		// 审计日志, 请求与响应经过脱敏与截断
+		var msg, rspBody proto.Message
+		var err error
+		audit := s.getAuditor().Start(nCtx, cmd.VersionedPath())
+		defer func() {
+			audit.End(cmd.GetAuditOption(), msg, rspBody, err)
+		}()
+
+		err = s.limitBody(c, cmd)
+		if err != nil {
+			log.Errorf("err:%v", err)
+			handler.Error(err)
+			return
+		}
 
 		// 拼装 request
-		reqT := t.In(1).Elem()
-		reqV := reflect.New(reqT)
-		msg := reqV.Interface().(proto.Message)
+		msg = newReqF()
+
+		// 根据协议来, 由 HttpRule 生成的命令还会绑定路径参数与 query 参数
+		err = bindReq(c, cmd, c.Request.Body, msg, nCtx.ProtocolType())
+		if err != nil {
+			log.Errorf("err:%v", err)
+			err = toReadBodyErr(err)
+			handler.Error(err)
+			return
+		}
 
-		// 根据协议来
-		err := handler.UnmarshalerByProtocol(c.Request.Body, msg, nCtx.ProtocolType())
+		// 与 GinPost 一致, 参数校验先于登录态校验与鉴权
+		err = validateF(msg)
+		if err != nil {
+			handler.Error(err)
+			return
+		}
+
+		err = s.checkAuth(nCtx, cmd)
 		if err != nil {
 			log.Errorf("err:%v", err)
 			handler.Error(err)
 			return
 		}
+		s.markDeprecated(c, nCtx, cmd)
+
+		// 鉴权
+		err = cmd.Authorize(nCtx, msg, s.policyList...)
+		if err != nil {
+			audit.SetEvent(baudit.EventPermissionDenied)
+			handler.ErrorWithStatus(http.StatusForbidden, err)
+			return
+		}
 
-		handlerRet := v.Call([]reflect.Value{reflect.ValueOf(nCtx), reqV})
+		// 响应缓存
+		cacheTk, hit, err := s.lookupCache(c, nCtx, cmd, msg)
+		if err != nil {
+			log.Errorf("err:%v", err)
+			handler.Error(err)
+			return
+		}
+		if hit {
+			return
+		}
 
-		// 检查是否有误
-		var callRes error
-		if !handlerRet[1].IsNil() {
-			callRes = handlerRet[1].Interface().(error)
+		// 幂等键去重
+		ticket, replayed, err := s.reserveIdempotency(c, nCtx, cmd, msg)
+		if err != nil {
+			log.Errorf("err:%v", err)
+			handler.Error(err)
+			return
+		}
+		if replayed {
+			return
 		}
 
-		if callRes != nil {
-			log.Errorf("err:%v", callRes)
-			handler.Error(callRes)
+		rspBody, err = callWithDeadline(nCtx, callF, msg)
+		if err != nil {
+			log.Errorf("err:%v", err)
+			s.releaseIdempotency(ticket)
+			handler.Error(err)
 			return
 		}
 
 		// 检查返回值
-		if handlerRet[0].IsValid() && !handlerRet[0].IsNil() {
-			rspBody, ok := handlerRet[0].Interface().(proto.Message)
-			if !ok {
-				log.Errorf("proto.Marshal err %v", err)
-				handler.Error(lberr.NewErr(500, "not proto.Message"))
+		if rspBody != nil {
+			s.saveIdempotency(ticket, rspBody)
+			if cacheTk != nil && s.storeCache(c, nCtx, cacheTk, rspBody) == nil {
 				return
 			}
-
 			handler.RespByProtocol(rspBody, nCtx.ProtocolType())
 			return
 		}
 
 		// 走到这里说明走不动了
-		handler.Error(lberr.NewInvalidArg("un ok"))
-	})
+		s.releaseIdempotency(ticket)
+		err = lberr.NewInvalidArg("un ok")
+		handler.Error(err)
+	})))
 }
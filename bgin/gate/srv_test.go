@@ -0,0 +1,74 @@
+package gate
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/oldbai555/micro/blog"
+	"github.com/oldbai555/micro/bprometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithMetrics(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	s := NewSvr("test", 0, nil, nil, WithMetrics("", bprometheus.WithRegistry(prometheus.NewRegistry()), bprometheus.WithBasicAuth("admin", "secret")))
+	router := s.newRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, bprometheus.PrometheusUrl, nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("no auth: status %d", w.Code)
+	}
+
+	bprometheus.GateMetrics.Begin("/v1/user/get")(bprometheus.CodeOK, 10, 20)
+	r := httptest.NewRequest(http.MethodGet, bprometheus.PrometheusUrl, nil)
+	r.SetBasicAuth("admin", "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "lb_gate_requests_total") {
+		t.Fatal("missing gate metrics")
+	}
+
+	// 只开放 GET
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, bprometheus.PrometheusUrl, nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("POST: status %d", w.Code)
+	}
+}
+
+func TestWithLogAdmin(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	s := NewSvr("test", 0, nil, nil, WithLogAdmin("", gin.BasicAuth(gin.Accounts{"admin": "secret"})))
+	router := s.newRouter()
+
+	// 文档中的方法均已挂载
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete} {
+		r := httptest.NewRequest(method, blog.LevelUrl+"?pkg=github.com/oldbai555/micro/bgin&level=info", nil)
+		r.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status %d", method, w.Code)
+		}
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, blog.LevelUrl, nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s no auth: status %d", method, w.Code)
+		}
+	}
+
+	// 没有鉴权时不挂载
+	router = NewSvr("test", 0, nil, nil, WithLogAdmin("")).newRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, blog.LevelUrl, nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("without auth handlers: status %d", w.Code)
+	}
+}
@@ -0,0 +1,452 @@
+package gate
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/lbtool/pkg/jsonpb"
+	"github.com/oldbai555/lbtool/pkg/lberr"
+	"github.com/oldbai555/micro/baudit"
+	"github.com/oldbai555/micro/bcmd"
+	"github.com/oldbai555/micro/bconst"
+	"github.com/oldbai555/micro/bgin"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStreamHeartbeat = time.Second * 15
+
+	// 浏览器的 EventSource / WebSocket 不能自定义请求头, sid 可以通过 cookie 传递
+	// 不接受 query 中的 sid, 避免凭证写入访问日志, 代理日志与浏览器历史
+	streamCookieSid = "lb_sid"
+	// WebSocket 还可以通过子协议传递 sid, 如 new WebSocket(url, ["lb.sid", sid]), 握手时回应 lb.sid
+	streamWsSidProtocol = "lb.sid"
+	headerWsProtocol    = "Sec-WebSocket-Protocol"
+)
+
+var _ grpc.ServerStream = (*gateStream)(nil)
+
+// authorizeErr Authorize 拒绝的请求, SSE 在写出响应头之前以 403 返回
+type authorizeErr struct {
+	error
+}
+
+func (e authorizeErr) Unwrap() error {
+	return e.error
+}
+
+// streamUCtx 流式调用的上下文, 客户端断开时会被取消
+type streamUCtx struct {
+	*GinUCtx
+	ctx context.Context
+}
+
+func (u *streamUCtx) Deadline() (deadline time.Time, ok bool) {
+	return u.ctx.Deadline()
+}
+
+func (u *streamUCtx) Done() <-chan struct{} {
+	return u.ctx.Done()
+}
+
+func (u *streamUCtx) Err() error {
+	return u.ctx.Err()
+}
+
+func (u *streamUCtx) Value(key interface{}) interface{} {
+	return u.ctx.Value(key)
+}
+
+// gateStream 将 grpc.ServerStream 桥接到 SSE / WebSocket
+type gateStream struct {
+	nCtx    *streamUCtx
+	decodeF func(m proto.Message) error
+	sendF   func(m proto.Message) error
+	recved  bool
+}
+
+func (g *gateStream) SetHeader(metadata.MD) error {
+	return nil
+}
+
+func (g *gateStream) SendHeader(metadata.MD) error {
+	return nil
+}
+
+func (g *gateStream) SetTrailer(metadata.MD) {}
+
+func (g *gateStream) Context() context.Context {
+	return g.nCtx
+}
+
+func (g *gateStream) SendMsg(m interface{}) error {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return lberr.NewErr(http.StatusInternalServerError, "not proto.Message")
+	}
+	if err := g.nCtx.Err(); err != nil {
+		return err
+	}
+	return g.sendF(msg)
+}
+
+// RecvMsg 服务端流式方法只会读取一次请求
+func (g *gateStream) RecvMsg(m interface{}) error {
+	if g.recved {
+		return io.EOF
+	}
+	g.recved = true
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return lberr.NewErr(http.StatusInternalServerError, "not proto.Message")
+	}
+	return g.decodeF(msg)
+}
+
+// encodeStreamMsg apijson 协议输出 json, 否则输出 base64 编码的 pb
+func encodeStreamMsg(m proto.Message, protocolType string) (string, error) {
+	if protocolType == bconst.PROTO_TYPE_API_JSON {
+		return jsonpb.MarshalToString(m)
+	}
+	buf, err := proto.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+func (s *Svr) newStreamHandler(cmd *bcmd.Cmd) gin.HandlerFunc {
+	return withCmdLimit(cmd, func(c *gin.Context) {
+		handler := bgin.NewHandler(c)
+
+		nCtx := s.newUCtx(c, cmd)
+		if nCtx.Sid() == "" {
+			nCtx.SetSid(streamSid(c))
+		}
+		err := s.checkAuth(nCtx, cmd)
+		if err != nil {
+			log.Errorf("err:%v", err)
+			handler.Error(err)
+			return
+		}
+		s.markDeprecated(c, nCtx, cmd)
+
+		err = s.limitBody(c, cmd)
+		if err != nil {
+			log.Errorf("err:%v", err)
+			handler.Error(err)
+			return
+		}
+
+		if isWebSocket(c.Request) {
+			s.serveWebSocket(c, cmd, nCtx)
+			return
+		}
+		s.serveSSE(c, cmd, nCtx)
+	})
+}
+
+func isWebSocket(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// wsProtocolList 客户端请求的 WebSocket 子协议
+func wsProtocolList(r *http.Request) []string {
+	var list []string
+	for _, val := range r.Header.Values(headerWsProtocol) {
+		for _, protocol := range strings.Split(val, ",") {
+			list = append(list, strings.TrimSpace(protocol))
+		}
+	}
+	return list
+}
+
+// streamSid 请求头中没有 sid 时, 依次取 cookie 与 WebSocket 子协议中的 sid
+func streamSid(c *gin.Context) string {
+	if sid, err := c.Cookie(streamCookieSid); err == nil && sid != "" {
+		return sid
+	}
+	if !isWebSocket(c.Request) {
+		return ""
+	}
+	list := wsProtocolList(c.Request)
+	for i, protocol := range list {
+		if protocol == streamWsSidProtocol && i+1 < len(list) {
+			return list[i+1]
+		}
+	}
+	return ""
+}
+
+func (s *Svr) newDecodeF(c *gin.Context, cmd *bcmd.Cmd, nCtx *streamUCtx, body []byte) func(m proto.Message) error {
+	return func(m proto.Message) error {
+		err := bindReq(c, cmd, bytes.NewReader(body), m, nCtx.ProtocolType())
+		if err != nil {
+			log.Errorf("err:%v", err)
+			return err
+		}
+		// EventSource 只能发 GET, 没有声明 HttpRule 的流式命令仍从 query 取参数
+		if cmd.HttpRule == nil && c.Request.URL.RawQuery != "" {
+			err = bindParams(c, m, true, nil)
+			if err != nil {
+				log.Errorf("err:%v", err)
+				return err
+			}
+		}
+		err = cmd.Authorize(nCtx, m, s.policyList...)
+		if err != nil {
+			audit := s.getAuditor().Start(nCtx, cmd.VersionedPath())
+			audit.SetEvent(baudit.EventPermissionDenied)
+			audit.End(cmd.GetAuditOption(), m, nil, err)
+			return authorizeErr{err}
+		}
+		return nil
+	}
+}
+
+func (s *Svr) serveSSE(c *gin.Context, cmd *bcmd.Cmd, ginUCtx *GinUCtx) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		bgin.NewHandler(c).Error(toReadBodyErr(err))
+		return
+	}
+
+	// 客户端断开时 request 的 context 会被取消
+	ctx, cancel := context.WithCancel(ginUCtx)
+	defer cancel()
+	nCtx := &streamUCtx{GinUCtx: ginUCtx, ctx: ctx}
+
+	var lock sync.Mutex
+	var committed bool
+	// commit 写出 SSE 的响应头, 在请求解析与鉴权通过后才调用, 之前的错误仍按普通请求返回对应的状态码
+	// 调用方需持有 lock
+	commit := func() {
+		if committed {
+			return
+		}
+		committed = true
+		header := c.Writer.Header()
+		header.Set(bconst.HttpHeaderContentType, "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		header.Set("Connection", "keep-alive")
+		header.Set(bconst.ProtocolType, nCtx.ProtocolType())
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+	}
+	write := func(format string, args ...interface{}) error {
+		lock.Lock()
+		defer lock.Unlock()
+		commit()
+		_, err := fmt.Fprintf(c.Writer, format, args...)
+		if err != nil {
+			cancel()
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	// 返回前等心跳协程退出, 之后 gin 会复用 c.Writer
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	decodeF := s.newDecodeF(c, cmd, nCtx, body)
+	stream := &gateStream{
+		nCtx: nCtx,
+		decodeF: func(m proto.Message) error {
+			err := decodeF(m)
+			if err != nil {
+				return err
+			}
+			lock.Lock()
+			commit()
+			lock.Unlock()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.heartbeat(ctx, func() error {
+					return write(": heartbeat\n\n")
+				})
+			}()
+			return nil
+		},
+		sendF: func(m proto.Message) error {
+			data, err := encodeStreamMsg(m, nCtx.ProtocolType())
+			if err != nil {
+				log.Errorf("err:%v", err)
+				return err
+			}
+			return write("data: %s\n\n", data)
+		},
+	}
+
+	err = cmd.GRpcFunc.(bcmd.StreamFunc)(stream)
+	if err != nil && ctx.Err() == nil {
+		log.Errorf("err:%v", err)
+		lock.Lock()
+		started := committed
+		lock.Unlock()
+		if !started {
+			var aErr authorizeErr
+			if errors.As(err, &aErr) {
+				bgin.NewHandler(c).ErrorWithStatus(http.StatusForbidden, aErr.error)
+				return
+			}
+			bgin.NewHandler(c).Error(err)
+			return
+		}
+		_ = write("event: error\ndata: %s\n\n", streamErrData(err))
+	}
+}
+
+func (s *Svr) serveWebSocket(c *gin.Context, cmd *bcmd.Cmd, ginUCtx *GinUCtx) {
+	// Cors 中间件放行的 "*" 对 WebSocket 不够, 按 NewWebSocketOriginChecker 再校验一次
+	originF := s.wsOriginF
+	if originF == nil {
+		originF = bgin.NewWebSocketOriginChecker(bgin.DefaultCorsConfig())
+	}
+	wsSrv := websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			if !originF(r) {
+				log.Warnf("websocket: origin %s not allowed, path:%s", r.Header.Get(bconst.HeaderOrigin), r.URL.Path)
+				return fmt.Errorf("origin %s not allowed", r.Header.Get(bconst.HeaderOrigin))
+			}
+			// 通过子协议传递 sid 时只回应 lb.sid, 不回显 sid
+			for _, protocol := range config.Protocol {
+				if protocol == streamWsSidProtocol {
+					config.Protocol = []string{streamWsSidProtocol}
+					break
+				}
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			// 长连接不受 http.Server 读写超时的限制
+			_ = ws.SetDeadline(time.Time{})
+
+			ctx, cancel := context.WithCancel(ginUCtx)
+			defer cancel()
+			nCtx := &streamUCtx{GinUCtx: ginUCtx, ctx: ctx}
+
+			// 第一帧为请求体
+			var body []byte
+			err := websocket.Message.Receive(ws, &body)
+			if err != nil {
+				log.Errorf("err:%v", err)
+				return
+			}
+
+			var lock sync.Mutex
+			write := func(payloadType byte, data []byte) error {
+				lock.Lock()
+				defer lock.Unlock()
+				ws.PayloadType = payloadType
+				_, err := ws.Write(data)
+				if err != nil {
+					cancel()
+				}
+				return err
+			}
+
+			// 后续读失败说明客户端已断开
+			go func() {
+				var ignore []byte
+				for websocket.Message.Receive(ws, &ignore) == nil {
+				}
+				cancel()
+			}()
+
+			stream := &gateStream{
+				nCtx:    nCtx,
+				decodeF: s.newDecodeF(c, cmd, nCtx, body),
+				sendF: func(m proto.Message) error {
+					if nCtx.ProtocolType() == bconst.PROTO_TYPE_API_JSON {
+						data, err := jsonpb.Marshal(m)
+						if err != nil {
+							log.Errorf("err:%v", err)
+							return err
+						}
+						return write(websocket.TextFrame, data)
+					}
+					data, err := proto.Marshal(m)
+					if err != nil {
+						log.Errorf("err:%v", err)
+						return err
+					}
+					return write(websocket.BinaryFrame, data)
+				},
+			}
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.heartbeat(ctx, func() error {
+					return write(websocket.PingFrame, nil)
+				})
+			}()
+			defer func() {
+				cancel()
+				wg.Wait()
+			}()
+
+			err = cmd.GRpcFunc.(bcmd.StreamFunc)(stream)
+			if err != nil && ctx.Err() == nil {
+				log.Errorf("err:%v", err)
+				_ = write(websocket.TextFrame, []byte(streamErrData(err)))
+			}
+		},
+	}
+	wsSrv.ServeHTTP(c.Writer, c.Request)
+}
+
+func (s *Svr) heartbeat(ctx context.Context, ping func() error) {
+	interval := s.streamHeartbeat
+	if interval <= 0 {
+		interval = defaultStreamHeartbeat
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ping(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// streamErrData 错误以 {errcode, errmsg} 的形式下发
+func streamErrData(err error) string {
+	var aErr authorizeErr
+	if errors.As(err, &aErr) {
+		err = aErr.error
+	}
+	code := lberr.GetErrCode(err)
+	if code == -1 {
+		code = http.StatusInternalServerError
+	}
+	return fmt.Sprintf(`{"errcode":%d,"errmsg":%q}`, code, lberr.GetErrMsgByErr(err))
+}
@@ -0,0 +1,40 @@
+package gate
+
+import (
+	"embed"
+	"github.com/gin-gonic/gin"
+	"github.com/oldbai555/lbtool/log"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// swaggerUiVersion 内置的 swagger-ui-dist 版本, 与下面 go:generate 中的版本保持一致
+const swaggerUiVersion = "5.17.14"
+
+//go:generate sh -c "curl -sSfL https://registry.npmjs.org/swagger-ui-dist/-/swagger-ui-dist-5.17.14.tgz | tar -xz -C swaggerui --strip-components=1 package/swagger-ui.css package/swagger-ui-bundle.js package/LICENSE"
+
+//go:embed swaggerui
+var swaggerUiFS embed.FS
+
+const swaggerUiDir = "swaggerui"
+
+// registerSwaggerUi 在 prefix 下输出内置的 swagger-ui 资源, 版本固定, 允许客户端缓存
+func registerSwaggerUi(router gin.IRouter, prefix string) {
+	_, err := fs.Stat(swaggerUiFS, path.Join(swaggerUiDir, "swagger-ui-bundle.js"))
+	if err != nil {
+		log.Warnf("swagger-ui %s is not bundled, run go generate ./bgin/gate", swaggerUiVersion)
+	}
+	router.GET(prefix+"/*filepath", func(c *gin.Context) {
+		name := strings.TrimPrefix(c.Param("filepath"), "/")
+		buf, err := fs.ReadFile(swaggerUiFS, path.Join(swaggerUiDir, name))
+		if err != nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=86400")
+		c.Data(http.StatusOK, mime.TypeByExtension(path.Ext(name)), buf)
+	})
+}
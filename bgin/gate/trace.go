@@ -0,0 +1,40 @@
+package gate
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/oldbai555/micro/bcmd"
+	"github.com/oldbai555/micro/bprometheus"
+	"github.com/oldbai555/micro/btrace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// withCmdTrace 每个命令一个服务端 span, span 写入 c.Request 的 ctx, nCtx 与下游调用随之延续链路
+// 请求带 traceparent 时沿用上游的链路, 否则由 X-LB-TRACE-ID 还原 trace id
+func withCmdTrace(cmd *bcmd.Cmd, h gin.HandlerFunc) gin.HandlerFunc {
+	route := cmd.VersionedPath()
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := btrace.Start(ctx, route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethodKey.String(c.Request.Method),
+				semconv.HTTPRouteKey.String(route),
+			),
+		)
+		c.Request = c.Request.WithContext(ctx)
+		defer func() {
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(c.Writer.Status()))
+			if code := cmdCode(c); code != bprometheus.CodeOK {
+				span.SetAttributes(attribute.String("lb.errcode", code))
+				span.SetStatus(codes.Error, code)
+			}
+			span.End()
+		}()
+		h(c)
+	}
+}
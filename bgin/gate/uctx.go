@@ -1,20 +1,48 @@
 package gate
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/oldbai555/micro/uctx"
+	"time"
 )
 
 var _ uctx.IUCtx = (*GinUCtx)(nil)
 
+// NewGinUCtx 以 c.Request 的 context 为父 context, 网关的处理超时需在此之前写入 c.Request
 func NewGinUCtx(ctx *gin.Context) *GinUCtx {
+	var parent context.Context
+	if ctx.Request != nil {
+		parent = ctx.Request.Context()
+	}
 	return &GinUCtx{
 		Context:  ctx,
-		BaseUCtx: uctx.NewBaseUCtx(),
+		BaseUCtx: uctx.New(parent),
 	}
 }
 
+// GinUCtx *gin.Context 与 *uctx.BaseUCtx 都实现了 context.Context, 以下方法显式以 BaseUCtx 为准
 type GinUCtx struct {
 	*gin.Context
 	*uctx.BaseUCtx
 }
+
+func (u *GinUCtx) Deadline() (deadline time.Time, ok bool) {
+	return u.BaseUCtx.Deadline()
+}
+
+func (u *GinUCtx) Done() <-chan struct{} {
+	return u.BaseUCtx.Done()
+}
+
+func (u *GinUCtx) Err() error {
+	return u.BaseUCtx.Err()
+}
+
+// Value 先取身份字段与 request 的 context value, 再取 c.Set 写入的值
+func (u *GinUCtx) Value(key interface{}) interface{} {
+	if val := u.BaseUCtx.Value(key); val != nil {
+		return val
+	}
+	return u.Context.Value(key)
+}
@@ -0,0 +1,170 @@
+package gate
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/oldbai555/micro/bcmd"
+	"github.com/oldbai555/micro/bconst"
+	"github.com/oldbai555/micro/bgin"
+	"github.com/oldbai555/micro/bprometheus"
+	"github.com/oldbai555/micro/uctx"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"sync"
+)
+
+const (
+	unknownCaller   = "unknown"
+	otherCaller     = "other"
+	maxCallerBytes  = 64
+	maxCallerValues = 100
+)
+
+// deprecatedCmdCounter 已废弃命令的调用次数, 据此判断旧版本何时可以下线
+var deprecatedCmdCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "lb",
+	Subsystem: "gate",
+	Name:      "deprecated_cmd_total",
+	Help:      "Number of calls to deprecated cmds, partitioned by path, version and caller.",
+}, []string{"path", "version", "caller"})
+
+func init() {
+	bprometheus.Register(deprecatedCmdCounter)
+}
+
+// callerLabels 限制 caller 标签的取值数量, 调用方标识来自请求头, 不加限制时指标的时序数量会无限增长
+type callerLabels struct {
+	allowMap map[string]bool // 白名单, 为空时按 maxCallerValues 限制
+
+	mu      sync.Mutex
+	seenMap map[string]bool
+}
+
+func (l *callerLabels) label(caller string) string {
+	if caller == "" {
+		return unknownCaller
+	}
+	if l.allowMap != nil {
+		if l.allowMap[caller] {
+			return caller
+		}
+		return otherCaller
+	}
+	if len(caller) > maxCallerBytes {
+		caller = caller[:maxCallerBytes]
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.seenMap[caller] {
+		return caller
+	}
+	if len(l.seenMap) >= maxCallerValues {
+		return otherCaller
+	}
+	if l.seenMap == nil {
+		l.seenMap = map[string]bool{}
+	}
+	l.seenMap[caller] = true
+	return caller
+}
+
+// CallerFunc 返回调用方标识, 用于统计废弃命令的调用方, 取值数量由 WithCallerList 或 maxCallerValues 限制
+type CallerFunc func(nCtx uctx.IUCtx) string
+
+// defaultCaller 取请求头 X-LB-CALLER, 如 ios/3.2.0
+func defaultCaller(nCtx uctx.IUCtx) string {
+	ginUCtx, ok := nCtx.(*GinUCtx)
+	if !ok {
+		return ""
+	}
+	return ginUCtx.GetHeader(bconst.GinHeaderCaller)
+}
+
+// markDeprecated 已废弃的命令响应 Deprecation 与 Sunset 头, 并按调用方计数
+func (s *Svr) markDeprecated(c *gin.Context, nCtx uctx.IUCtx, cmd *bcmd.Cmd) {
+	if !cmd.IsDeprecated() {
+		return
+	}
+	c.Header(bconst.HeaderDeprecation, "true")
+	if !cmd.Sunset.IsZero() {
+		c.Header(bconst.HeaderSunset, cmd.Sunset.UTC().Format(http.TimeFormat))
+	}
+
+	callerF := s.callerF
+	if callerF == nil {
+		callerF = defaultCaller
+	}
+	caller := s.callers.label(callerF(nCtx))
+	deprecatedCmdCounter.WithLabelValues(cmd.Path, cmd.Version, caller).Inc()
+}
+
+type versionRoute struct {
+	method string
+	path   string
+}
+
+type versionHandler struct {
+	cmd     *bcmd.Cmd
+	handler gin.HandlerFunc
+}
+
+// registerCmdList 注册命令, 声明了版本的命令同时注册 /{version}{path}
+// 同一路径存在多个版本时, 不带前缀的路径按 Accept-Version 分发
+func (s *Svr) registerCmdList(router *gin.Engine) {
+	var routeList []versionRoute
+	groupMap := map[versionRoute][]*versionHandler{}
+	for _, cmd := range s.cmdList {
+		h := s.newCmdHandler(cmd)
+		methodList := []string{cmd.GetApiMethod()}
+		if cmd.IsStream() {
+			methodList = []string{http.MethodGet, http.MethodPost}
+		}
+		for _, method := range methodList {
+			if cmd.Version != "" {
+				router.Handle(method, cmd.VersionedPath(), h)
+			}
+			route := versionRoute{method: method, path: cmd.Path}
+			if _, ok := groupMap[route]; !ok {
+				routeList = append(routeList, route)
+			}
+			groupMap[route] = append(groupMap[route], &versionHandler{cmd: cmd, handler: h})
+		}
+	}
+
+	for _, route := range routeList {
+		list := groupMap[route]
+		if len(list) == 1 && list[0].cmd.Version == "" {
+			router.Handle(route.method, route.path, list[0].handler)
+			continue
+		}
+		router.Handle(route.method, route.path, newVersionDispatcher(list))
+	}
+}
+
+// newVersionDispatcher 按 Accept-Version 选择版本
+// 未带该头时优先使用未声明版本的命令, 否则使用最低的版本, 新增版本不影响老的客户端
+func newVersionDispatcher(list []*versionHandler) gin.HandlerFunc {
+	handlerMap := map[string]gin.HandlerFunc{}
+	var def *versionHandler
+	for _, vh := range list {
+		handlerMap[vh.cmd.Version] = vh.handler
+		if def == nil || def.cmd.Version != "" &&
+			(vh.cmd.Version == "" || bcmd.CompareVersion(vh.cmd.Version, def.cmd.Version) < 0) {
+			def = vh
+		}
+	}
+
+	return func(c *gin.Context) {
+		c.Writer.Header().Add(bconst.HeaderVary, bconst.HeaderAcceptVersion)
+		version := c.GetHeader(bconst.HeaderAcceptVersion)
+		if version == "" {
+			def.handler(c)
+			return
+		}
+		h, ok := handlerMap[bcmd.NormalizeVersion(version)]
+		if !ok {
+			bgin.NewHandler(c).Error(bgin.NewHttpStatusErr(http.StatusNotAcceptable, "unsupported version %s of %s", version, def.cmd.Path))
+			return
+		}
+		h(c)
+	}
+}
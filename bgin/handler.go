@@ -2,6 +2,7 @@ package bgin
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/render"
@@ -47,12 +48,12 @@ func (r *Handler) GetQuery(key string) (string, bool) {
 
 func (r *Handler) RespByJson(httpCode int, errCode int32, data string, errMsg string) {
 	r.C.Header(bconst.HttpHeaderContentType, bconst.HttpHeaderContentTypeByJson)
+	r.C.Set(bconst.GinCtxErrCode, errCode)
 	hint := r.C.Value(bconst.LogWithHint)
 	if data == "" {
 		data = "{}"
 	}
 	template := apiRspTemplate(data, errCode, errMsg, fmt.Sprintf("%s", hint))
-	log.Infof("jsonRsp:%v", template)
 
 	w := r.C.Writer
 	r.C.Status(httpCode)
@@ -104,22 +105,21 @@ func (r *Handler) RespByProtocol(pb proto.Message, protocolType string) {
 }
 
 func (r *Handler) UnmarshalerByProtocol(reader io.ReadCloser, pb proto.Message, protocolType string) error {
-	var err error
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(reader)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return err
+	}
+	// 空请求体, 比如 GET 请求
+	if buf.Len() == 0 {
+		return nil
+	}
 	switch protocolType {
 	case bconst.PROTO_TYPE_API_JSON:
-		var buf []byte
-		reader.Read(buf)
-		err = jsonpb.Unmarshal(buf, pb)
+		err = jsonpb.Unmarshal(buf.Bytes(), pb)
 	case bconst.PROTO_TYPE_PROTO3:
-		var buf bytes.Buffer
-		_, err := buf.ReadFrom(reader)
-		if err != nil {
-			log.Errorf("err:%v", err)
-			return err
-		}
-		if err == nil {
-			err = proto.Unmarshal(buf.Bytes(), pb)
-		}
+		err = proto.Unmarshal(buf.Bytes(), pb)
 	}
 	if err != nil {
 		log.Errorf("err:%v", err)
@@ -139,23 +139,41 @@ func (r *Handler) Success(data proto.Message) {
 	if tmp == "" {
 		tmp = "{}"
 	}
-	log.Infof("rsp: %s", tmp)
 	r.RespByJson(http.StatusOK, 0, tmp, bconst.DefaultRspMsg)
 }
 
 // 响应错误
 func (r *Handler) Error(err error) {
+	var e IHttpStatusErr
+	if errors.As(err, &e) {
+		r.ErrorWithStatus(e.HttpStatus(), err)
+		return
+	}
+	r.ErrorWithStatus(http.StatusOK, err)
+}
+
+// ErrorWithStatus 响应错误, 并指定 http 状态码
+func (r *Handler) ErrorWithStatus(httpCode int, err error) {
+	if e, ok := err.(*HttpStatusErr); ok {
+		err = e.Unwrap()
+	}
+
 	if e, ok := err.(*lberr.Error); ok {
-		r.RespByJson(http.StatusOK, e.Code(), "", e.Message())
+		r.RespByJson(httpCode, e.Code(), "", e.Message())
+		return
+	}
+
+	if e, ok := err.(iCodeErr); ok {
+		r.RespByJson(httpCode, e.Code(), "", e.Message())
 		return
 	}
 
 	if e, ok := status.FromError(err); ok {
-		r.RespByJson(http.StatusOK, int32(e.Code()), "", e.Message())
+		r.RespByJson(httpCode, int32(e.Code()), "", e.Message())
 		return
 	}
 
-	r.RespByJson(http.StatusOK, http.StatusInternalServerError, "", err.Error())
+	r.RespByJson(httpCode, http.StatusInternalServerError, "", err.Error())
 }
 
 func (r *Handler) HttpJson(val interface{}) {
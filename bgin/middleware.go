@@ -1,65 +1,59 @@
 package bgin
 
 import (
+	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/oldbai555/lbtool/log"
 	"github.com/oldbai555/lbtool/utils"
 	"github.com/oldbai555/micro/bconst"
+	"github.com/oldbai555/micro/blog"
+	"github.com/oldbai555/micro/btrace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
-// Cors 跨域配制
-func Cors() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		if v := c.GetHeader(bconst.HeaderAccessControlAllowOrigin); v == "" {
-			c.Header(bconst.HeaderAccessControlAllowOrigin, "*")
-		}
-
-		if v := c.GetHeader(bconst.HeaderAccessControlAllowHeaders); v == "" {
-			c.Header("Access-Control-Allow-Headers", "Origin,Content-Type,AccessToken,X-CSRF-Token,Authorization,Token,X-Requested-With")
-		}
-
-		if v := c.GetHeader(bconst.HeaderAccessControlAllowMethods); v == "" {
-			c.Header("Access-Control-Allow-Methods", "POST, GET") // 只放行 POST GET
-		}
-
-		if v := c.GetHeader(bconst.HeaderAccessControlExposeHeaders); v == "" {
-			c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Content-Type")
-		}
-
-		if v := c.GetHeader(bconst.HeaderAccessControlAllowCredentials); v == "" {
-			c.Header("Access-Control-Allow-Credentials", "true")
-		}
+// TraceId 当前请求的链路Id, 同一个请求只生成一次, RegisterUuidTrace 与网关的 nCtx 使用同一个值
+// 上游带了 X-LB-TRACE-ID 时在其后追加一段 uuid, 第一段 uuid 不变, 与 btrace 的 trace id 对应
+// 只带了 W3C traceparent 时取其 trace id, 网关的 span 延续上游链路, 日志与审计记录随之对应
+func TraceId(c *gin.Context) string {
+	traceId := c.GetString(bconst.LogWithHint)
+	if traceId != "" {
+		return traceId
+	}
 
-		method := c.Request.Method
-		if method == http.MethodOptions {
-			c.AbortWithStatus(http.StatusNoContent)
+	hint := c.GetHeader(bconst.GinHeaderTraceId)
+	if hint != "" {
+		traceId = fmt.Sprintf("%s.%s", hint, utils.GenUUID())
+	} else {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			traceId = btrace.FormatTraceId(sc.TraceID())
+		} else {
+			traceId = utils.GenUUID()
 		}
 	}
+	c.Set(bconst.LogWithHint, traceId)
+	c.Request.Header.Set(bconst.GinHeaderTraceId, traceId)
+	return traceId
 }
 
 // RegisterUuidTrace 注册一个链路Id进入日志中
+// log.SetLogHint 按协程保存, 只对当前协程的 lbtool 日志生效, 请求结束时清除
+// 需要在其他协程或结构化的日志中带上链路Id时使用 blog.FromCtx
 func RegisterUuidTrace() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var traceId string
-		hint := c.Value(bconst.LogWithHint)
-		if hint == nil || fmt.Sprintf("%v", hint) == "" {
-			hint = c.GetHeader(bconst.GinHeaderTraceId)
-		}
-
-		if hint == "" {
-			traceId = utils.GenUUID()
-		} else {
-			traceId = fmt.Sprintf("%v.%s", hint, utils.GenUUID())
-		}
+		traceId := TraceId(c)
+		c.Header(bconst.GinHeaderTraceId, traceId)
 		log.SetLogHint(traceId)
+		defer log.SetLogHint("")
 
-		c.Set(bconst.LogWithHint, traceId)
-		c.Request.Header.Add(bconst.GinHeaderTraceId, traceId)
-
-		log.Infof("hint: %s , RemoteIP: %s , ClientIP: %s", traceId, c.RemoteIP(), c.ClientIP())
+		blog.FromCtx(c.Request.Context()).WithTraceId(traceId).Infof("RemoteIP: %s , ClientIP: %s", c.RemoteIP(), c.ClientIP())
 
 		c.Next()
 	}
@@ -101,3 +95,46 @@ func NewLogFormatter(svr string) func(param gin.LogFormatterParams) string {
 		return v
 	}
 }
+
+// LoggerWithBlog 访问日志通过 blog 输出, 带上 trace_id, method 等字段, 4xx 为 warn, 5xx 为 error
+// 可以与 NewLogFormatter 替换使用, 开启 blog.SetJson 后输出 json
+func LoggerWithBlog() gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{
+		Formatter: blogFormatter,
+		Output:    io.Discard,
+	})
+}
+
+// blogFormatter 直接通过 blog 输出, 返回的空串由 gin 写入 io.Discard
+func blogFormatter(param gin.LogFormatterParams) string {
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+
+	ctx := context.Background()
+	if param.Request != nil {
+		ctx = param.Request.Context()
+	}
+	l := blog.FromCtx(ctx)
+	if traceId, ok := param.Keys[bconst.LogWithHint].(string); ok && traceId != "" {
+		l = l.WithTraceId(traceId)
+	}
+	l = l.With("status", param.StatusCode).
+		With("latency", param.Latency.String()).
+		With("client_ip", param.ClientIP).
+		With("http_method", param.Method).
+		With("path", param.Path)
+	if param.ErrorMessage != "" {
+		l = l.With("error", strings.TrimSpace(param.ErrorMessage))
+	}
+
+	switch {
+	case param.StatusCode >= http.StatusInternalServerError:
+		l.Errorf("[GIN] %s %s", param.Method, param.Path)
+	case param.StatusCode >= http.StatusBadRequest:
+		l.Warnf("[GIN] %s %s", param.Method, param.Path)
+	default:
+		l.Infof("[GIN] %s %s", param.Method, param.Path)
+	}
+	return ""
+}
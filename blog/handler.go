@@ -0,0 +1,61 @@
+package blog
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// LevelUrl 管理接口默认挂载的路径
+const LevelUrl = "/log/level"
+
+// levelsResp 管理接口的响应, 等级均为名称, 如 debug
+type levelsResp struct {
+	Default  string            `json:"default"`
+	Packages map[string]string `json:"packages"`
+}
+
+// LevelHandler 运行时查看与调整日志等级的管理接口
+//
+//	GET                          查看默认等级与各包的等级
+//	PUT/POST ?level=info         设置默认等级
+//	PUT/POST ?pkg=xxx&level=info 设置包及其子包的等级
+//	DELETE   ?pkg=xxx            取消包的单独设置
+//
+// 接口本身不做鉴权, 挂载时需要加上鉴权或只在内网开放
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pkg := r.URL.Query().Get("pkg")
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			level, err := ParseLevel(r.URL.Query().Get("level"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if pkg == "" {
+				SetDefaultLevel(level)
+			} else {
+				SetLevel(pkg, level)
+			}
+		case http.MethodDelete:
+			if pkg == "" {
+				http.Error(w, "missing pkg", http.StatusBadRequest)
+				return
+			}
+			ResetLevel(pkg)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		def, pkgMap := Levels()
+		resp := levelsResp{Default: LevelName(def), Packages: make(map[string]string, len(pkgMap))}
+		for p, level := range pkgMap {
+			resp.Packages[p] = LevelName(level)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
@@ -0,0 +1,127 @@
+package blog
+
+import (
+	"fmt"
+	"github.com/oldbai555/lbtool/utils"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var levelToNameMap = map[utils.Level]string{
+	utils.LevelDebug: "debug",
+	utils.LevelInfo:  "info",
+	utils.LevelWarn:  "warn",
+	utils.LevelError: "error",
+}
+
+// LevelName 日志等级的名称, 如 debug
+func LevelName(level utils.Level) string {
+	if name, ok := levelToNameMap[level]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", level)
+}
+
+// ParseLevel 支持 debug, info, warn, error, 不区分大小写
+func ParseLevel(name string) (utils.Level, error) {
+	for level, val := range levelToNameMap {
+		if strings.EqualFold(name, val) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// levelTable 写时复制, 读取时不加锁
+type levelTable struct {
+	def     utils.Level
+	min     utils.Level // 所有配置中最低的等级, 低于它的日志不必再取调用方
+	pkgList []string    // 按长度降序, 优先匹配更具体的包
+	pkgMap  map[string]utils.Level
+}
+
+var (
+	levelMu  sync.Mutex
+	levelVal atomic.Value // *levelTable
+)
+
+func init() {
+	levelVal.Store(&levelTable{def: utils.LevelDebug, pkgMap: map[string]utils.Level{}})
+}
+
+func loadLevels() *levelTable {
+	return levelVal.Load().(*levelTable)
+}
+
+// updateLevels 在副本上修改后替换
+func updateLevels(f func(t *levelTable)) {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	old := loadLevels()
+	t := &levelTable{def: old.def, pkgMap: make(map[string]utils.Level, len(old.pkgMap))}
+	for pkg, level := range old.pkgMap {
+		t.pkgMap[pkg] = level
+	}
+	f(t)
+	for pkg := range t.pkgMap {
+		t.pkgList = append(t.pkgList, pkg)
+	}
+	sort.Slice(t.pkgList, func(i, j int) bool {
+		return len(t.pkgList[i]) > len(t.pkgList[j])
+	})
+	t.min = t.def
+	for _, level := range t.pkgMap {
+		if level < t.min {
+			t.min = level
+		}
+	}
+	levelVal.Store(t)
+}
+
+// levelOf 包自身或最近的上级包的等级, 都没有设置时使用默认等级
+func (t *levelTable) levelOf(pkg string) utils.Level {
+	for _, prefix := range t.pkgList {
+		if pkg == prefix || strings.HasPrefix(pkg, prefix+"/") {
+			return t.pkgMap[prefix]
+		}
+	}
+	return t.def
+}
+
+// SetDefaultLevel 没有单独设置的包使用的等级, 默认 debug
+func SetDefaultLevel(level utils.Level) {
+	updateLevels(func(t *levelTable) {
+		t.def = level
+	})
+}
+
+// SetLevel 设置包及其子包的等级, pkg 为完整的导入路径, 如 github.com/oldbai555/micro/gormx
+func SetLevel(pkg string, level utils.Level) {
+	updateLevels(func(t *levelTable) {
+		t.pkgMap[pkg] = level
+	})
+}
+
+// ResetLevel 取消包的单独设置, 恢复为上级包或默认等级
+func ResetLevel(pkg string) {
+	updateLevels(func(t *levelTable) {
+		delete(t.pkgMap, pkg)
+	})
+}
+
+// GetLevel 包当前生效的等级
+func GetLevel(pkg string) utils.Level {
+	return loadLevels().levelOf(pkg)
+}
+
+// Levels 默认等级与各包单独设置的等级
+func Levels() (utils.Level, map[string]utils.Level) {
+	t := loadLevels()
+	pkgMap := make(map[string]utils.Level, len(t.pkgMap))
+	for pkg, level := range t.pkgMap {
+		pkgMap[pkg] = level
+	}
+	return t.def, pkgMap
+}
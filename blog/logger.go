@@ -0,0 +1,309 @@
+package blog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/lbtool/utils"
+	"github.com/oldbai555/micro/btrace"
+	"github.com/oldbai555/micro/uctx"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// callerSkip output -> Debugf 等 -> 调用方
+const callerSkip = 2
+
+type methodKey struct{}
+
+var (
+	serviceName atomic.Value // string
+	jsonMode    atomic.Bool
+
+	outputMu sync.RWMutex
+	output   io.Writer
+)
+
+// SetService 每行日志的 service 字段, 服务启动时设置
+func SetService(name string) {
+	serviceName.Store(name)
+}
+
+func getService() string {
+	name, _ := serviceName.Load().(string)
+	return name
+}
+
+// SetJson 每行输出一个 json 对象, 便于日志平台采集, 默认输出文本
+func SetJson(on bool) {
+	jsonMode.Store(on)
+}
+
+// SetOutput 日志的输出, 默认与 lbtool 的 log 共用同一个 writer, 传 nil 时恢复默认
+func SetOutput(w io.Writer) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	output = w
+}
+
+func getOutput() io.Writer {
+	outputMu.RLock()
+	defer outputMu.RUnlock()
+	if output != nil {
+		return output
+	}
+	return log.GetWriter()
+}
+
+// WithMethod 记录当前处理的接口, 网关为命令路径, grpc 为方法全名
+func WithMethod(ctx context.Context, method string) context.Context {
+	return context.WithValue(ctx, methodKey{}, method)
+}
+
+// MethodFromContext WithMethod 写入的接口, 没有时返回空串
+func MethodFromContext(ctx context.Context) string {
+	method, _ := ctx.Value(methodKey{}).(string)
+	return method
+}
+
+// Field 附加的结构化字段
+type Field struct {
+	Key string
+	Val interface{}
+}
+
+// Logger 与请求绑定的日志, 每行带上 trace_id, sid, service, method
+// 字段保存在 Logger 中而不是按协程保存, 并发的请求之间不会串
+type Logger struct {
+	traceId   string
+	sid       string
+	method    string
+	skipList  []string
+	fieldList []Field
+}
+
+// FromCtx 由 uctx 取 trace id 与 sid, 没有 uctx 时使用 ctx 中 span 的 trace id
+// 如: blog.FromCtx(nCtx).Infof("user:%d", userId)
+func FromCtx(ctx context.Context) *Logger {
+	l := &Logger{}
+	if ctx == nil {
+		return l
+	}
+	nCtx, err := uctx.ToUCtx(ctx)
+	if err == nil {
+		l.traceId = nCtx.TraceId()
+		l.sid = nCtx.Sid()
+	}
+	if l.traceId == "" {
+		l.traceId = btrace.TraceIdFromContext(ctx)
+	}
+	l.method = MethodFromContext(ctx)
+	return l
+}
+
+func (l *Logger) clone() *Logger {
+	n := *l
+	n.fieldList = append([]Field{}, l.fieldList...)
+	n.skipList = append([]string{}, l.skipList...)
+	return &n
+}
+
+// With 返回附加了字段的 Logger, 原 Logger 不变
+func (l *Logger) With(key string, val interface{}) *Logger {
+	n := l.clone()
+	n.fieldList = append(n.fieldList, Field{Key: key, Val: val})
+	return n
+}
+
+// WithTraceId 覆盖 trace id, 用于没有 uctx 的场景, 如 gin 的访问日志
+func (l *Logger) WithTraceId(traceId string) *Logger {
+	n := l.clone()
+	n.traceId = traceId
+	return n
+}
+
+// WithMethod 覆盖 method 字段
+func (l *Logger) WithMethod(method string) *Logger {
+	n := l.clone()
+	n.method = method
+	return n
+}
+
+// WithCallerSkip 输出的调用位置跳过前缀匹配的包, 用于框架内部打日志时指向业务代码, 如 gorm 的 sql 日志
+// 包级别的等级仍按直接调用 Logger 的包判断
+func (l *Logger) WithCallerSkip(prefixList ...string) *Logger {
+	n := l.clone()
+	n.skipList = append(n.skipList, prefixList...)
+	return n
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.output(utils.LevelDebug, format, args...)
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.output(utils.LevelInfo, format, args...)
+}
+
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.output(utils.LevelWarn, format, args...)
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.output(utils.LevelError, format, args...)
+}
+
+func (l *Logger) output(level utils.Level, format string, args ...interface{}) {
+	t := loadLevels()
+	if level < t.min {
+		return
+	}
+
+	pc, file, line, ok := runtime.Caller(callerSkip)
+	var pkg, fn string
+	if ok {
+		pkg, fn = splitFuncName(runtime.FuncForPC(pc).Name())
+	}
+	if level < t.levelOf(pkg) {
+		return
+	}
+	caller := formatCaller(pkg, file, line, fn)
+	if len(l.skipList) > 0 {
+		caller = callerOutside(l.skipList)
+	}
+
+	msg := fmt.Sprintf(format, args...)
+	var buf []byte
+	if jsonMode.Load() {
+		buf = l.encodeJson(level, caller, msg)
+	} else {
+		buf = l.encodeText(level, caller, msg)
+	}
+	_, err := getOutput().Write(buf)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "blog: write err:%v\n", err)
+	}
+}
+
+// splitFuncName 拆分 runtime 的函数名, 如 github.com/a/b.(*T).F 拆为 github.com/a/b 与 (*T).F
+func splitFuncName(name string) (string, string) {
+	slashIndex := strings.LastIndex(name, "/")
+	if slashIndex < 0 {
+		slashIndex = 0
+	}
+	dotIndex := strings.Index(name[slashIndex:], ".")
+	if dotIndex < 0 {
+		return name, ""
+	}
+	return name[:slashIndex+dotIndex], name[slashIndex+dotIndex+1:]
+}
+
+// encodeText 与 lbtool 的格式保持一致, <> 中为 trace id, 其余字段以 key=val 追加在末尾
+// service(pid) <trace_id> 2006-01-02T15:04:05.0000 INFO pkg/file.go:12:Func msg sid=xx method=xx
+func (l *Logger) encodeText(level utils.Level, caller, msg string) []byte {
+	var b bytes.Buffer
+	b.WriteString(fmt.Sprintf("%s(%d) <%s> ", getService(), os.Getpid(), l.traceId))
+	b.WriteString(time.Now().Format("2006-01-02T15:04:05.0000"))
+	b.WriteString(" ")
+	b.WriteString(utils.LevelToStrMap[level])
+	b.WriteString(" ")
+	b.WriteString(caller)
+	b.WriteString(" ")
+	b.WriteString(msg)
+
+	writeField := func(key string, val interface{}) {
+		s := fmt.Sprint(val)
+		if s == "" {
+			return
+		}
+		if strings.ContainsAny(s, " \t\n\"=") {
+			s = strconv.Quote(s)
+		}
+		b.WriteString(" ")
+		b.WriteString(key)
+		b.WriteString("=")
+		b.WriteString(s)
+	}
+	writeField("sid", l.sid)
+	writeField("method", l.method)
+	for _, f := range l.fieldList {
+		writeField(f.Key, f.Val)
+	}
+	b.WriteString("\n")
+	return b.Bytes()
+}
+
+// encodeJson 字段顺序固定, 空的 trace_id, sid 等不输出
+func (l *Logger) encodeJson(level utils.Level, caller, msg string) []byte {
+	var b bytes.Buffer
+	b.WriteString("{")
+	first := true
+	writeField := func(key string, val interface{}) {
+		if s, ok := val.(string); ok && s == "" {
+			return
+		}
+		v, err := json.Marshal(val)
+		if err != nil {
+			v, _ = json.Marshal(fmt.Sprint(val))
+		}
+		k, _ := json.Marshal(key)
+		if !first {
+			b.WriteString(",")
+		}
+		first = false
+		b.Write(k)
+		b.WriteString(":")
+		b.Write(v)
+	}
+	writeField("time", time.Now().Format(time.RFC3339Nano))
+	writeField("level", LevelName(level))
+	writeField("service", getService())
+	writeField("trace_id", l.traceId)
+	writeField("sid", l.sid)
+	writeField("method", l.method)
+	writeField("caller", caller)
+	writeField("msg", msg)
+	for _, f := range l.fieldList {
+		writeField(f.Key, f.Val)
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+func formatCaller(pkg, file string, line int, fn string) string {
+	return fmt.Sprintf("%s:%d:%s", path.Join(pkg, path.Base(file)), line, fn)
+}
+
+// callerOutside 从直接调用 Logger 的位置往上, 第一个不属于 prefixList 中包的位置
+func callerOutside(prefixList []string) string {
+	pcs := make([]uintptr, 32)
+	// runtime.Callers 比 runtime.Caller 多计 Callers 自身, 再加上 callerOutside 这一层
+	n := runtime.Callers(callerSkip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		pkg, fn := splitFuncName(frame.Function)
+		inside := pkg == "runtime"
+		for _, prefix := range prefixList {
+			if strings.HasPrefix(pkg, prefix) {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			return formatCaller(pkg, frame.File, frame.Line, fn)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package bprometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+const namespace = "lb"
+
+// CodeOK 成功调用的 code 标签
+const CodeOK = "OK"
+
+var sizeBuckets = prometheus.ExponentialBuckets(64, 4, 8)
+
+// RpcMetrics 一类调用的请求数, 耗时, 并发数与请求/响应大小
+// method 标签取路由模板或 grpc 方法全名, 不使用原始 URI, 避免标签数量失控
+type RpcMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+	reqSize  *prometheus.HistogramVec
+	rspSize  *prometheus.HistogramVec
+}
+
+func NewRpcMetrics(subsystem string) *RpcMetrics {
+	return &RpcMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "requests_total",
+			Help:      "Number of requests, partitioned by method and code.",
+		}, []string{"method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Request latency in seconds, partitioned by method and code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "in_flight_requests",
+			Help:      "Number of requests currently being processed, partitioned by method.",
+		}, []string{"method"}),
+		reqSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "request_size_bytes",
+			Help:      "Request size in bytes, partitioned by method.",
+			Buckets:   sizeBuckets,
+		}, []string{"method"}),
+		rspSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "response_size_bytes",
+			Help:      "Response size in bytes, partitioned by method.",
+			Buckets:   sizeBuckets,
+		}, []string{"method"}),
+	}
+}
+
+// Collectors 用于注册到自定义的 Registry
+func (m *RpcMetrics) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.requests, m.duration, m.inFlight, m.reqSize, m.rspSize}
+}
+
+// Begin 开始一次调用, 返回的函数在调用结束时执行, 大小未知时传入负数
+func (m *RpcMetrics) Begin(method string) func(code string, reqSize, rspSize int) {
+	start := time.Now()
+	inFlight := m.inFlight.WithLabelValues(method)
+	inFlight.Inc()
+	return func(code string, reqSize, rspSize int) {
+		inFlight.Dec()
+		m.requests.WithLabelValues(method, code).Inc()
+		m.duration.WithLabelValues(method, code).Observe(time.Since(start).Seconds())
+		if reqSize >= 0 {
+			m.reqSize.WithLabelValues(method).Observe(float64(reqSize))
+		}
+		if rspSize >= 0 {
+			m.rspSize.WithLabelValues(method).Observe(float64(rspSize))
+		}
+	}
+}
+
+var (
+	// GrpcServerMetrics grpc 服务端, method 为 /pkg.Service/Method
+	GrpcServerMetrics = NewRpcMetrics("grpc_server")
+	// GrpcClientMetrics grpc 客户端, 通过 discover 建立的连接
+	GrpcClientMetrics = NewRpcMetrics("grpc_client")
+	// GateMetrics 网关命令, method 为带版本前缀的 Cmd.Path
+	GateMetrics = NewRpcMetrics("gate")
+	// HttpClientMetrics 服务间 http 调用, method 为 srv + path
+	HttpClientMetrics = NewRpcMetrics("http_client")
+)
+
+func init() {
+	for _, m := range []*RpcMetrics{GrpcServerMetrics, GrpcClientMetrics, GateMetrics, HttpClientMetrics} {
+		Register(m.Collectors()...)
+	}
+}
@@ -0,0 +1,212 @@
+package bprometheus
+
+import (
+	"errors"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	dto "github.com/prometheus/client_model/go"
+	"net"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// exporter 输出指标的配置, 默认使用全局的 Registry, 不做鉴权
+type exporter struct {
+	registerer prometheus.Registerer
+	gatherers  gatherers
+
+	username string
+	password string
+
+	ipAllow     bool
+	allowIpList []*net.IPNet
+
+	buildInfo *BuildInfo
+}
+
+// BuildInfo 通过 lb_build_info 输出, 值恒为 1
+type BuildInfo struct {
+	Service string
+	Version string
+	Commit  string
+}
+
+type Option func(*exporter)
+
+// WithRegistry 使用独立的 Registry, 并注册 go 与 process 的指标
+// 框架内置的指标 (见 Register) 随之转移到 reg, 业务注册在全局 Registry 的指标需要输出时加上 WithGatherer(prometheus.DefaultGatherer)
+func WithRegistry(reg *prometheus.Registry) Option {
+	return func(e *exporter) {
+		e.registerer = reg
+		e.gatherers = gatherers{reg}
+		register(reg, collectors.NewGoCollector())
+		register(reg, collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+		useRegistry(reg)
+	}
+}
+
+// WithGatherer 追加输出的指标来源, 与之前的来源同名的指标以之前的为准
+func WithGatherer(g prometheus.Gatherer) Option {
+	return func(e *exporter) {
+		e.gatherers = append(e.gatherers, g)
+	}
+}
+
+// WithBasicAuth 访问指标需要 basic auth
+func WithBasicAuth(username, password string) Option {
+	return func(e *exporter) {
+		e.username = username
+		e.password = password
+	}
+}
+
+// WithAllowIps 只允许列表中的来源访问, 支持 IP 与 CIDR, 如 10.0.0.0/8
+// 只看连接的对端地址, 不信任 X-Forwarded-For, 无效的条目会被忽略, 全部无效时拒绝所有访问
+func WithAllowIps(list ...string) Option {
+	return func(e *exporter) {
+		e.ipAllow = true
+		for _, item := range list {
+			ipNet, err := parseIpNet(item)
+			if err != nil {
+				log.Errorf("err:%v", err)
+				continue
+			}
+			e.allowIpList = append(e.allowIpList, ipNet)
+		}
+	}
+}
+
+// WithBuildInfo 输出 lb_build_info{service,version,commit,go_version}
+func WithBuildInfo(service, version, commit string) Option {
+	return func(e *exporter) {
+		e.buildInfo = &BuildInfo{Service: service, Version: version, Commit: commit}
+	}
+}
+
+func newExporter(opts ...Option) *exporter {
+	e := &exporter{
+		registerer: prometheus.DefaultRegisterer,
+		gatherers:  gatherers{prometheus.DefaultGatherer},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.buildInfo != nil {
+		register(e.registerer, newBuildInfoCollector(e.buildInfo))
+	}
+	return e
+}
+
+func newBuildInfoCollector(info *BuildInfo) prometheus.Collector {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "build_info",
+		Help:      "A metric with a constant '1' value labeled by service, version, commit and go version.",
+		ConstLabels: prometheus.Labels{
+			"service":    info.Service,
+			"version":    info.Version,
+			"commit":     info.Commit,
+			"go_version": runtime.Version(),
+		},
+	}, func() float64 { return 1 })
+}
+
+// gatherers 合并多个指标来源, 同名的指标只取第一个来源的
+// 独立的 Registry 与全局 Registry 都有 go 与 process 的指标, 直接用 prometheus.Gatherers 合并会因重复而输出失败
+type gatherers []prometheus.Gatherer
+
+func (gs gatherers) Gather() ([]*dto.MetricFamily, error) {
+	var errs prometheus.MultiError
+	var mfList []*dto.MetricFamily
+	seenMap := map[string]bool{}
+	for _, g := range gs {
+		list, err := g.Gather()
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, mf := range list {
+			if seenMap[mf.GetName()] {
+				continue
+			}
+			seenMap[mf.GetName()] = true
+			mfList = append(mfList, mf)
+		}
+	}
+	sort.Slice(mfList, func(i, j int) bool {
+		return mfList[i].GetName() < mfList[j].GetName()
+	})
+	return mfList, errs.MaybeUnwrap()
+}
+
+// framework 框架内置的指标, 默认注册在全局 Registry, WithRegistry 后转移到独立的 Registry
+var framework = struct {
+	sync.Mutex
+	list    []prometheus.Collector
+	regList []prometheus.Registerer
+}{regList: []prometheus.Registerer{prometheus.DefaultRegisterer}}
+
+// Register 注册框架内置的指标, 如网关与 orm 的指标, 重复注册时忽略
+// 注册到全局 Registry, 配置了 WithRegistry 时注册到该 Registry
+func Register(list ...prometheus.Collector) {
+	framework.Lock()
+	defer framework.Unlock()
+	framework.list = append(framework.list, list...)
+	for _, r := range framework.regList {
+		for _, c := range list {
+			register(r, c)
+		}
+	}
+}
+
+// useRegistry 已注册与之后注册的框架指标都注册到 reg, 并从全局 Registry 中移除, 避免同时输出两份
+func useRegistry(reg prometheus.Registerer) {
+	framework.Lock()
+	defer framework.Unlock()
+	for _, r := range framework.regList {
+		if r == reg {
+			return
+		}
+	}
+	if len(framework.regList) == 1 && framework.regList[0] == prometheus.DefaultRegisterer {
+		for _, c := range framework.list {
+			prometheus.DefaultRegisterer.Unregister(c)
+		}
+		framework.regList = nil
+	}
+	framework.regList = append(framework.regList, reg)
+	for _, c := range framework.list {
+		register(reg, c)
+	}
+}
+
+// register 重复注册时忽略
+func register(r prometheus.Registerer, c prometheus.Collector) {
+	err := r.Register(c)
+	if err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			return
+		}
+		log.Errorf("err:%v", err)
+	}
+}
+
+func parseIpNet(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		_, ipNet, err := net.ParseCIDR(s)
+		return ipNet, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, &net.ParseError{Type: "IP address", Text: s}
+	}
+	bits := 8 * net.IPv4len
+	if ip.To4() == nil {
+		bits = 8 * net.IPv6len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
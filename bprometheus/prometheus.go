@@ -2,19 +2,69 @@ package bprometheus
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"github.com/oldbai555/lbtool/log"
 	"github.com/oldbai555/lbtool/pkg/signal"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"net"
 	"net/http"
 	"os"
 )
 
 const PrometheusUrl = "/metrics"
 
-func StartPrometheusMonitor(ip string, port uint32) error {
+// Handler 输出指标的 http.Handler, 可以挂到网关等其他路由上
+func Handler(opts ...Option) http.Handler {
+	e := newExporter(opts...)
+	h := promhttp.InstrumentMetricHandler(e.registerer, promhttp.HandlerFor(e.gatherers, promhttp.HandlerOpts{}))
+	return e.withAuth(h)
+}
+
+func (e *exporter) withAuth(h http.Handler) http.Handler {
+	if e.username == "" && !e.ipAllow {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if e.ipAllow && !e.isAllowIp(r.RemoteAddr) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		if e.username != "" {
+			username, password, ok := r.BasicAuth()
+			if !ok ||
+				subtle.ConstantTimeCompare([]byte(username), []byte(e.username)) != 1 ||
+				subtle.ConstantTimeCompare([]byte(password), []byte(e.password)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (e *exporter) isAllowIp(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range e.allowIpList {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func StartPrometheusMonitor(ip string, port uint32, opts ...Option) error {
 	srv := http.NewServeMux()
-	srv.Handle(PrometheusUrl, promhttp.Handler())
+	srv.Handle(PrometheusUrl, Handler(opts...))
 	s := &http.Server{
 		Addr:    fmt.Sprintf("%s:%d", ip, port),
 		Handler: srv,
@@ -39,3 +89,15 @@ func StartPrometheusMonitor(ip string, port uint32) error {
 
 	return nil
 }
+
+// Push 推送到 Pushgateway, 用于无法被拉取的批处理任务, 通常在任务结束时调用
+// 以 job 分组, 同一个 job 之前推送的指标会被整体替换
+func Push(gatewayUrl, job string, opts ...Option) error {
+	e := newExporter(opts...)
+	err := push.New(gatewayUrl, job).Gatherer(e.gatherers).Push()
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package bprometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, h http.Handler, r *http.Request) (int, string) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	buf, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, string(buf)
+}
+
+func gatherNames(t *testing.T, g prometheus.Gatherer) map[string]bool {
+	mfList, err := g.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nameMap := map[string]bool{}
+	for _, mf := range mfList {
+		nameMap[mf.GetName()] = true
+	}
+	return nameMap
+}
+
+func TestWithRegistry(t *testing.T) {
+	GateMetrics.Begin("/v1/user/get")(CodeOK, 10, 20)
+	if !gatherNames(t, prometheus.DefaultGatherer)["lb_gate_requests_total"] {
+		t.Fatal("framework metrics should be registered on the default registry")
+	}
+
+	global := prometheus.NewCounter(prometheus.CounterOpts{Name: "lb_test_global_total", Help: "test"})
+	prometheus.MustRegister(global)
+	defer prometheus.Unregister(global)
+	global.Inc()
+
+	reg := prometheus.NewRegistry()
+	h := Handler(WithRegistry(reg), WithBuildInfo("user", "v1.0.0", "abc"))
+
+	// 之后注册的框架指标同样注册到 reg
+	late := prometheus.NewCounter(prometheus.CounterOpts{Name: "lb_test_late_total", Help: "test"})
+	Register(late)
+	late.Inc()
+
+	code, body := scrape(t, h, httptest.NewRequest(http.MethodGet, PrometheusUrl, nil))
+	if code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	for _, name := range []string{"lb_gate_requests_total", "lb_test_late_total", "lb_build_info", "go_goroutines"} {
+		if !strings.Contains(body, name) {
+			t.Errorf("missing %s", name)
+		}
+	}
+	if strings.Contains(body, "lb_test_global_total") {
+		t.Error("metrics on the default registry should not be exported")
+	}
+
+	nameMap := gatherNames(t, prometheus.DefaultGatherer)
+	if nameMap["lb_gate_requests_total"] || nameMap["lb_test_late_total"] {
+		t.Error("framework metrics should be moved off the default registry")
+	}
+
+	// 加上全局的来源后一并输出
+	_, body = scrape(t, Handler(WithRegistry(reg), WithGatherer(prometheus.DefaultGatherer)), httptest.NewRequest(http.MethodGet, PrometheusUrl, nil))
+	if !strings.Contains(body, "lb_test_global_total") || !strings.Contains(body, "lb_gate_requests_total") {
+		t.Error("WithGatherer should export the default registry as well")
+	}
+}
+
+func TestWithBasicAuth(t *testing.T) {
+	h := Handler(WithRegistry(prometheus.NewRegistry()), WithBasicAuth("admin", "secret"))
+
+	r := httptest.NewRequest(http.MethodGet, PrometheusUrl, nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("no auth: status %d", w.Code)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, PrometheusUrl, nil)
+	r.SetBasicAuth("admin", "wrong")
+	if code, _ := scrape(t, h, r); code != http.StatusUnauthorized {
+		t.Fatalf("wrong password: status %d", code)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, PrometheusUrl, nil)
+	r.SetBasicAuth("admin", "secret")
+	if code, _ := scrape(t, h, r); code != http.StatusOK {
+		t.Fatalf("right password: status %d", code)
+	}
+}
+
+func TestWithAllowIps(t *testing.T) {
+	h := Handler(WithRegistry(prometheus.NewRegistry()), WithAllowIps("10.0.0.0/8", "192.168.1.1", "::1", "bad"))
+	for remoteAddr, want := range map[string]int{
+		"10.1.2.3:1234":    http.StatusOK,
+		"192.168.1.1:1234": http.StatusOK,
+		"192.168.1.2:1234": http.StatusForbidden,
+		"[::1]:1234":       http.StatusOK,
+		"[::2]:1234":       http.StatusForbidden,
+	} {
+		r := httptest.NewRequest(http.MethodGet, PrometheusUrl, nil)
+		r.RemoteAddr = remoteAddr
+		// 不信任 X-Forwarded-For
+		r.Header.Set("X-Forwarded-For", "10.0.0.1")
+		if code, _ := scrape(t, h, r); code != want {
+			t.Errorf("%s: status %d, want %d", remoteAddr, code, want)
+		}
+	}
+
+	// 全部无效时拒绝所有访问
+	h = Handler(WithRegistry(prometheus.NewRegistry()), WithAllowIps("bad"))
+	r := httptest.NewRequest(http.MethodGet, PrometheusUrl, nil)
+	r.RemoteAddr = "10.1.2.3:1234"
+	if code, _ := scrape(t, h, r); code != http.StatusForbidden {
+		t.Fatalf("invalid list: status %d", code)
+	}
+}
@@ -0,0 +1,83 @@
+package bredis
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/oldbai555/micro/bprometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"strings"
+	"time"
+)
+
+var (
+	// cmdDurationHistogram 命令耗时, 管道按一次调用统计, command 为 pipeline
+	cmdDurationHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "lb",
+		Subsystem: "redis",
+		Name:      "command_duration_seconds",
+		Help:      "Redis command latency in seconds, partitioned by node and command.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"node", "command"})
+
+	// cmdErrCounter 命令失败次数, 不包括 redis.Nil
+	cmdErrCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lb",
+		Subsystem: "redis",
+		Name:      "command_errors_total",
+		Help:      "Number of failed redis commands, partitioned by node and command.",
+	}, []string{"node", "command"})
+)
+
+func init() {
+	bprometheus.Register(cmdDurationHistogram, cmdErrCounter)
+}
+
+type startKey struct{}
+
+// metricsHook 按节点与命令统计耗时与失败次数
+type metricsHook struct {
+	node string
+}
+
+var _ redis.Hook = (*metricsHook)(nil)
+
+func newMetricsHook(node string) *metricsHook {
+	return &metricsHook{node: node}
+}
+
+func (h *metricsHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, startKey{}, time.Now()), nil
+}
+
+func (h *metricsHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.observe(ctx, strings.ToLower(cmd.Name()), cmd.Err())
+	return nil
+}
+
+func (h *metricsHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, startKey{}, time.Now()), nil
+}
+
+func (h *metricsHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil && !errors.Is(cmd.Err(), redis.Nil) {
+			err = cmd.Err()
+			break
+		}
+	}
+	h.observe(ctx, "pipeline", err)
+	return nil
+}
+
+func (h *metricsHook) observe(ctx context.Context, command string, err error) {
+	start, ok := ctx.Value(startKey{}).(time.Time)
+	if !ok {
+		return
+	}
+	cmdDurationHistogram.WithLabelValues(h.node, command).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, redis.Nil) {
+		cmdErrCounter.WithLabelValues(h.node, command).Inc()
+	}
+}
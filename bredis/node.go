@@ -116,6 +116,8 @@ func newNode(ip string, port int, username, password string) *Node {
 		Password: password,
 		Username: username,
 	})
+	n.client.AddHook(newMetricsHook(fmt.Sprintf("%s:%d", ip, port)))
+	n.client.AddHook(newTraceHook(fmt.Sprintf("%s:%d", ip, port)))
 	return n
 }
 
@@ -124,7 +126,22 @@ type Group struct {
 	service  string
 	password string
 	mu       sync.RWMutex
+	ctx      context.Context
 }
+
+// WithContext 返回绑定 ctx 的 Group, 命令随 ctx 取消, ctx 中有 span 时命令记录为子 span
+// 如: rds.WithContext(nCtx).Get(key)
+func (g *Group) WithContext(ctx context.Context) *Group {
+	return &Group{nodeList: g.nodeList, service: g.service, password: g.password, ctx: ctx}
+}
+
+func (g *Group) getCtx() context.Context {
+	if g.ctx != nil {
+		return g.ctx
+	}
+	return context.Background()
+}
+
 type nodeListSorter struct {
 	nodeList []*Node
 }
@@ -184,7 +201,7 @@ func (g *Group) Set(key string, val []byte, exp time.Duration) error {
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	err := node.Set(context.Background(), key, val, exp).Err()
+	err := node.Set(g.getCtx(), key, val, exp).Err()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
@@ -199,7 +216,7 @@ func (g *Group) SetAny(key string, val interface{}) error {
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	err := node.Set(context.Background(), key, val, 0).Err()
+	err := node.Set(g.getCtx(), key, val, 0).Err()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
@@ -216,7 +233,7 @@ func (g *Group) SetWithExpire(key string, val interface{}, expire time.Duration)
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	err := node.Set(context.Background(), key, val, expire).Err()
+	err := node.Set(g.getCtx(), key, val, expire).Err()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
@@ -241,7 +258,7 @@ func (g *Group) SetEx(key string, val interface{}, expire time.Duration) error {
 		return err
 	}
 
-	err = node.Set(context.Background(), key, buf, expire).Err()
+	err = node.Set(g.getCtx(), key, buf, expire).Err()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
@@ -255,7 +272,7 @@ func (g *Group) SetUint64(key string, val uint64, exp time.Duration) error {
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	err := node.Set(context.Background(), key, val, exp).Err()
+	err := node.Set(g.getCtx(), key, val, exp).Err()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
@@ -270,7 +287,7 @@ func (g *Group) SetRange(key string, offset int64, value string) error {
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	err := node.SetRange(context.Background(), key, offset, value).Err()
+	err := node.SetRange(g.getCtx(), key, offset, value).Err()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
@@ -311,7 +328,7 @@ func (g *Group) HLen(key string) (uint32, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	v := node.HLen(context.Background(), key)
+	v := node.HLen(g.getCtx(), key)
 	err := v.Err()
 	if err != nil {
 		if err == redis.Nil {
@@ -334,13 +351,13 @@ func (g *Group) HSetPb(key, subKey string, j proto.Message, exp time.Duration) e
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	err = node.HSet(context.Background(), key, subKey, string(val)).Err()
+	err = node.HSet(g.getCtx(), key, subKey, string(val)).Err()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
 	}
 	if exp > 0 {
-		err = node.Expire(context.Background(), key, exp).Err()
+		err = node.Expire(g.getCtx(), key, exp).Err()
 		if err != nil {
 			log.Errorf("err:%v", err)
 			return err
@@ -360,13 +377,13 @@ func (g *Group) HSetJson(key, subKey string, j interface{}, exp time.Duration) e
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	err = node.HSet(context.Background(), key, subKey, string(val)).Err()
+	err = node.HSet(g.getCtx(), key, subKey, string(val)).Err()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
 	}
 	if exp > 0 {
-		err = node.Expire(context.Background(), key, exp).Err()
+		err = node.Expire(g.getCtx(), key, exp).Err()
 		if err != nil {
 			log.Errorf("err:%v", err)
 			return err
@@ -386,14 +403,14 @@ func (g *Group) HSetJsonNX(key, subKey string, j interface{}, exp time.Duration,
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	res := node.HSetNX(context.Background(), key, subKey, string(val))
+	res := node.HSetNX(g.getCtx(), key, subKey, string(val))
 	err = res.Err()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
 	}
 	if exp > 0 {
-		err = node.Expire(context.Background(), key, exp).Err()
+		err = node.Expire(g.getCtx(), key, exp).Err()
 		if err != nil {
 			log.Errorf("err:%v", err)
 			return err
@@ -411,7 +428,7 @@ func (g *Group) Get(key string) ([]byte, error) {
 	if node == nil {
 		return nil, errors.New("not found available redis node")
 	}
-	val, err := node.Get(context.Background(), key).Bytes()
+	val, err := node.Get(g.getCtx(), key).Bytes()
 	if err != nil {
 		if err != redis.Nil {
 			log.Errorf("err:%v", err)
@@ -482,7 +499,7 @@ func (g *Group) GetIntDef(key string, def int) (int, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	val, err := node.Get(context.Background(), key).Int64()
+	val, err := node.Get(g.getCtx(), key).Int64()
 	if err != nil {
 		if err != redis.Nil {
 			log.Errorf("err:%v", err)
@@ -499,7 +516,7 @@ func (g *Group) GetInt64(key string) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	val, err := node.Get(context.Background(), key).Int64()
+	val, err := node.Get(g.getCtx(), key).Int64()
 	if err != nil {
 		if err != redis.Nil {
 			log.Errorf("err:%v", err)
@@ -515,7 +532,7 @@ func (g *Group) GetInt64Def(key string, def int64) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	val, err := node.Get(context.Background(), key).Int64()
+	val, err := node.Get(g.getCtx(), key).Int64()
 	if err != nil {
 		if err != redis.Nil {
 			log.Errorf("err:%v", err)
@@ -533,7 +550,7 @@ func (g *Group) HGetAll(key string) (map[string]string, error) {
 	if node == nil {
 		return nil, errors.New("not found available redis node")
 	}
-	return node.HGetAll(context.Background(), key).Result()
+	return node.HGetAll(g.getCtx(), key).Result()
 }
 
 func (g *Group) HKeys(key string) ([]string, error) {
@@ -542,7 +559,7 @@ func (g *Group) HKeys(key string) ([]string, error) {
 	if node == nil {
 		return nil, errors.New("not found available redis node")
 	}
-	return node.HKeys(context.Background(), key).Result()
+	return node.HKeys(g.getCtx(), key).Result()
 }
 
 func (g *Group) HScan(key string, cursor uint64, match string, count int64) ([]string, uint64, error) {
@@ -551,7 +568,7 @@ func (g *Group) HScan(key string, cursor uint64, match string, count int64) ([]s
 	if node == nil {
 		return nil, 0, errors.New("not found available redis node")
 	}
-	return node.HScan(context.Background(), key, cursor, match, count).Result()
+	return node.HScan(g.getCtx(), key, cursor, match, count).Result()
 }
 
 func (g *Group) ZScan(key string, cursor uint64, match string, count int64) ([]string, uint64, error) {
@@ -560,7 +577,7 @@ func (g *Group) ZScan(key string, cursor uint64, match string, count int64) ([]s
 	if node == nil {
 		return nil, 0, errors.New("not found available redis node")
 	}
-	return node.ZScan(context.Background(), key, cursor, match, count).Result()
+	return node.ZScan(g.getCtx(), key, cursor, match, count).Result()
 }
 
 func (g *Group) HMGetJson(key, subKey string, j interface{}) error {
@@ -569,7 +586,7 @@ func (g *Group) HMGetJson(key, subKey string, j interface{}) error {
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	values, err := node.HMGet(context.Background(), key, subKey).Result()
+	values, err := node.HMGet(g.getCtx(), key, subKey).Result()
 	if err != nil {
 		log.Errorf("redis HMGet err:%v", err)
 		return ErrRedisException
@@ -617,7 +634,7 @@ func (g *Group) HMBatchGetJson(key string, m map[string]interface{}) ([]string,
 	if node == nil {
 		return nil, errors.New("not found available redis node")
 	}
-	values, err := node.HMGet(context.Background(), key, subKeys...).Result()
+	values, err := node.HMGet(g.getCtx(), key, subKeys...).Result()
 	if err != nil {
 		log.Errorf("redis HMGet err:%v", err)
 		return nil, ErrRedisException
@@ -658,7 +675,7 @@ func (g *Group) HDel(key string, subKey ...string) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	delNum, err := node.HDel(context.Background(), key, subKey...).Result()
+	delNum, err := node.HDel(g.getCtx(), key, subKey...).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -672,7 +689,7 @@ func (g *Group) Del(key string) error {
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	err := node.Del(context.Background(), key).Err()
+	err := node.Del(g.getCtx(), key).Err()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
@@ -686,7 +703,7 @@ func (g *Group) ZAdd(key string, values ...*redis.Z) error {
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	err := node.ZAdd(context.Background(), key, values...).Err()
+	err := node.ZAdd(g.getCtx(), key, values...).Err()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
@@ -700,7 +717,7 @@ func (g *Group) ZCount(key, min, max string) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	v := node.ZCount(context.Background(), key, min, max)
+	v := node.ZCount(g.getCtx(), key, min, max)
 	err := v.Err()
 	if err != nil {
 		log.Errorf("err:%v", err)
@@ -715,7 +732,7 @@ func (g *Group) ZRangeByScore(key string, opt *redis.ZRangeBy) ([]string, error)
 	if node == nil {
 		return nil, errors.New("not found available redis node")
 	}
-	members, err := node.ZRangeByScore(context.Background(), key, opt).Result()
+	members, err := node.ZRangeByScore(g.getCtx(), key, opt).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return nil, err
@@ -729,7 +746,7 @@ func (g *Group) ZRangeByScoreWithScores(key string, opt *redis.ZRangeBy) ([]redi
 	if node == nil {
 		return nil, errors.New("not found available redis node")
 	}
-	resultList, err := node.ZRangeByScoreWithScores(context.Background(), key, opt).Result()
+	resultList, err := node.ZRangeByScoreWithScores(g.getCtx(), key, opt).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return nil, err
@@ -743,7 +760,7 @@ func (g *Group) ZIncrBy(key string, increment float64, member string) error {
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	_, err := node.ZIncrBy(context.Background(), key, increment, member).Result()
+	_, err := node.ZIncrBy(g.getCtx(), key, increment, member).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
@@ -757,7 +774,7 @@ func (g *Group) ZRange(key string, start, stop int64) ([]string, error) {
 	if node == nil {
 		return nil, errors.New("not found available redis node")
 	}
-	members, err := node.ZRange(context.Background(), key, start, stop).Result()
+	members, err := node.ZRange(g.getCtx(), key, start, stop).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return nil, err
@@ -771,7 +788,7 @@ func (g *Group) ZRangeWithScores(key string, start, stop int64) ([]redis.Z, erro
 	if node == nil {
 		return nil, errors.New("not found available redis node")
 	}
-	resultList, err := node.ZRangeWithScores(context.Background(), key, start, stop).Result()
+	resultList, err := node.ZRangeWithScores(g.getCtx(), key, start, stop).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return nil, err
@@ -785,7 +802,7 @@ func (g *Group) ZRevRange(key string, start, stop int64) ([]string, error) {
 	if node == nil {
 		return nil, errors.New("not found available redis node")
 	}
-	members, err := node.ZRevRange(context.Background(), key, start, stop).Result()
+	members, err := node.ZRevRange(g.getCtx(), key, start, stop).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return nil, err
@@ -799,7 +816,7 @@ func (g *Group) ZRevRangeWithScores(key string, start, stop int64) ([]redis.Z, e
 	if node == nil {
 		return nil, errors.New("not found available redis node")
 	}
-	resultList, err := node.ZRevRangeWithScores(context.Background(), key, start, stop).Result()
+	resultList, err := node.ZRevRangeWithScores(g.getCtx(), key, start, stop).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return nil, err
@@ -813,7 +830,7 @@ func (g *Group) ZRem(key string, members ...interface{}) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	delNum, err := node.ZRem(context.Background(), key, members...).Result()
+	delNum, err := node.ZRem(g.getCtx(), key, members...).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -827,7 +844,7 @@ func (g *Group) ZRemRangeByScore(key string, min, max string) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	delNum, err := node.ZRemRangeByScore(context.Background(), key, min, max).Result()
+	delNum, err := node.ZRemRangeByScore(g.getCtx(), key, min, max).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -841,7 +858,7 @@ func (g *Group) ZCard(key string) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	num, err := node.ZCard(context.Background(), key).Result()
+	num, err := node.ZCard(g.getCtx(), key).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -855,7 +872,7 @@ func (g *Group) SAdd(key string, values ...interface{}) error {
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	err := node.SAdd(context.Background(), key, values...).Err()
+	err := node.SAdd(g.getCtx(), key, values...).Err()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
@@ -869,7 +886,7 @@ func (g *Group) SRem(key string, members ...interface{}) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	delNum, err := node.SRem(context.Background(), key, members...).Result()
+	delNum, err := node.SRem(g.getCtx(), key, members...).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -883,7 +900,7 @@ func (g *Group) SCard(key string) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	num, err := node.SCard(context.Background(), key).Result()
+	num, err := node.SCard(g.getCtx(), key).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -897,7 +914,7 @@ func (g *Group) SIsMember(key string, members interface{}) (bool, error) {
 	if node == nil {
 		return false, errors.New("not found available redis node")
 	}
-	ok, err := node.SIsMember(context.Background(), key, members).Result()
+	ok, err := node.SIsMember(g.getCtx(), key, members).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return false, err
@@ -911,7 +928,7 @@ func (g *Group) SMembers(key string) ([]string, error) {
 	if node == nil {
 		return nil, errors.New("not found available redis node")
 	}
-	members, err := node.SMembers(context.Background(), key).Result()
+	members, err := node.SMembers(g.getCtx(), key).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return nil, err
@@ -925,7 +942,7 @@ func (g *Group) HIncrBy(key, field string, incr int64) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	n, err := node.HIncrBy(context.Background(), key, field, incr).Result()
+	n, err := node.HIncrBy(g.getCtx(), key, field, incr).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -939,7 +956,7 @@ func (g *Group) HIncr(key, field string) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	n, err := node.HIncrBy(context.Background(), key, field, 1).Result()
+	n, err := node.HIncrBy(g.getCtx(), key, field, 1).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -953,7 +970,7 @@ func (g *Group) IncrBy(key string, incr int64) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	n, err := node.IncrBy(context.Background(), key, incr).Result()
+	n, err := node.IncrBy(g.getCtx(), key, incr).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -967,7 +984,7 @@ func (g *Group) DecrBy(key string, decr int64) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	n, err := node.DecrBy(context.Background(), key, decr).Result()
+	n, err := node.DecrBy(g.getCtx(), key, decr).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -981,7 +998,7 @@ func (g *Group) HSet(key, field string, val interface{}) error {
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	err := node.HSet(context.Background(), key, field, val).Err()
+	err := node.HSet(g.getCtx(), key, field, val).Err()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
@@ -996,7 +1013,7 @@ func (g *Group) HMSet(key string, fields map[string]interface{}) error {
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	err := node.HMSet(context.Background(), key, fields).Err()
+	err := node.HMSet(g.getCtx(), key, fields).Err()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
@@ -1011,7 +1028,7 @@ func (g *Group) HGet(key, subKey string) (string, error) {
 	if node == nil {
 		return "", errors.New("not found available redis node")
 	}
-	val, err := node.HGet(context.Background(), key, subKey).Result()
+	val, err := node.HGet(g.getCtx(), key, subKey).Result()
 	if err != nil {
 		if err != redis.Nil {
 			log.Errorf("err:%v", err)
@@ -1027,7 +1044,7 @@ func (g *Group) HGetUint64(key, subKey string) (uint64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	val, err := node.HGet(context.Background(), key, subKey).Uint64()
+	val, err := node.HGet(g.getCtx(), key, subKey).Uint64()
 	if err != nil {
 		if err != redis.Nil {
 			log.Errorf("err:%v", err)
@@ -1043,7 +1060,7 @@ func (g *Group) HGetIntDef(key, subKey string, def int) (int, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	val, err := node.HGet(context.Background(), key, subKey).Int64()
+	val, err := node.HGet(g.getCtx(), key, subKey).Int64()
 	if err != nil {
 		if err != redis.Nil {
 			log.Errorf("err:%v", err)
@@ -1060,7 +1077,7 @@ func (g *Group) HGetInt(key, subKey string) (int, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	val, err := node.HGet(context.Background(), key, subKey).Int64()
+	val, err := node.HGet(g.getCtx(), key, subKey).Int64()
 	if err != nil {
 		if err != redis.Nil {
 			log.Errorf("err:%v", err)
@@ -1076,7 +1093,7 @@ func (g *Group) HGetJson(key, subKey string, j interface{}) error {
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	val, err := node.HGet(context.Background(), key, subKey).Result()
+	val, err := node.HGet(g.getCtx(), key, subKey).Result()
 	if err != nil {
 		if err != redis.Nil {
 			log.Errorf("err:%v", err)
@@ -1097,7 +1114,7 @@ func (g *Group) HGetPb(key, subKey string, j proto.Message) error {
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	val, err := node.HGet(context.Background(), key, subKey).Result()
+	val, err := node.HGet(g.getCtx(), key, subKey).Result()
 	if err != nil {
 		if err != redis.Nil {
 			log.Errorf("err:%v", err)
@@ -1118,7 +1135,7 @@ func (g *Group) Expire(key string, expiration time.Duration) error {
 	if node == nil {
 		return errors.New("not found available redis node")
 	}
-	_, err := node.Expire(context.Background(), key, expiration).Result()
+	_, err := node.Expire(g.getCtx(), key, expiration).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
@@ -1132,7 +1149,7 @@ func (g *Group) Exists(key string) (bool, error) {
 	if node == nil {
 		return false, errors.New("not found available redis node")
 	}
-	val, err := node.Exists(context.Background(), key).Result()
+	val, err := node.Exists(g.getCtx(), key).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return false, err
@@ -1150,7 +1167,7 @@ func (g *Group) HExists(key, field string) (bool, error) {
 	if node == nil {
 		return false, errors.New("not found available redis node")
 	}
-	exists, err := node.HExists(context.Background(), key, field).Result()
+	exists, err := node.HExists(g.getCtx(), key, field).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return false, err
@@ -1164,7 +1181,7 @@ func (g *Group) ScriptRun(lua string, keys []string, args ...interface{}) (inter
 		return nil, errors.New("not found available redis node")
 	}
 	script := redis.NewScript(lua)
-	result, err := script.Run(context.Background(), node, keys, args...).Result()
+	result, err := script.Run(g.getCtx(), node, keys, args...).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return nil, err
@@ -1177,7 +1194,7 @@ func (g *Group) EvalSha(luaSha1 string, keys []string, args ...interface{}) (int
 	if node == nil {
 		return nil, errors.New("not found available redis node")
 	}
-	result, err := node.EvalSha(context.Background(), luaSha1, keys, args...).Result()
+	result, err := node.EvalSha(g.getCtx(), luaSha1, keys, args...).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return nil, err
@@ -1190,7 +1207,7 @@ func (g *Group) ScriptLoad(luaScript string) (string, error) {
 	if node == nil {
 		return "", errors.New("not found available redis node")
 	}
-	luaSha1, err := node.ScriptLoad(context.Background(), luaScript).Result()
+	luaSha1, err := node.ScriptLoad(g.getCtx(), luaScript).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return "", err
@@ -1204,7 +1221,7 @@ func (g *Group) Incr(key string) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	val, err := node.Incr(context.Background(), key).Result()
+	val, err := node.Incr(g.getCtx(), key).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -1218,7 +1235,7 @@ func (g *Group) Decr(key string) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	val, err := node.Decr(context.Background(), key).Result()
+	val, err := node.Decr(g.getCtx(), key).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -1232,7 +1249,7 @@ func (g *Group) ExpireAt(key string, expiredAt time.Time) (bool, error) {
 	if node == nil {
 		return false, errors.New("not found available redis node")
 	}
-	ok, err := node.ExpireAt(context.Background(), key, expiredAt).Result()
+	ok, err := node.ExpireAt(g.getCtx(), key, expiredAt).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return false, err
@@ -1246,7 +1263,7 @@ func (g *Group) LPop(key string) (string, error) {
 	if node == nil {
 		return "", errors.New("not found available redis node")
 	}
-	val, err := node.LPop(context.Background(), key).Result()
+	val, err := node.LPop(g.getCtx(), key).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return "", err
@@ -1260,7 +1277,7 @@ func (g *Group) RPop(key string) (string, error) {
 	if node == nil {
 		return "", errors.New("not found available redis node")
 	}
-	val, err := node.RPop(context.Background(), key).Result()
+	val, err := node.RPop(g.getCtx(), key).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return "", err
@@ -1274,7 +1291,7 @@ func (g *Group) LPush(key string, values ...interface{}) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	count, err := node.LPush(context.Background(), key, values...).Result()
+	count, err := node.LPush(g.getCtx(), key, values...).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -1288,7 +1305,7 @@ func (g *Group) RPush(key string, values ...interface{}) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	count, err := node.RPush(context.Background(), key, values...).Result()
+	count, err := node.RPush(g.getCtx(), key, values...).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -1302,7 +1319,7 @@ func (g *Group) LRange(key string, start, stop int64) ([]string, error) {
 	if node == nil {
 		return []string{}, errors.New("not found available redis node")
 	}
-	result, err := node.LRange(context.Background(), key, start, stop).Result()
+	result, err := node.LRange(g.getCtx(), key, start, stop).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return []string{}, err
@@ -1316,7 +1333,7 @@ func (g *Group) LTrim(key string, start, stop int64) (string, error) {
 	if node == nil {
 		return "", errors.New("not found available redis node")
 	}
-	result, err := node.LTrim(context.Background(), key, start, stop).Result()
+	result, err := node.LTrim(g.getCtx(), key, start, stop).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return "", err
@@ -1330,7 +1347,7 @@ func (g *Group) LLen(key string) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	count, err := node.LLen(context.Background(), key).Result()
+	count, err := node.LLen(g.getCtx(), key).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -1344,7 +1361,7 @@ func (g *Group) LIndex(key string, index int64) (string, error) {
 	if node == nil {
 		return "", errors.New("not found available redis node")
 	}
-	val, err := node.LIndex(context.Background(), key, index).Result()
+	val, err := node.LIndex(g.getCtx(), key, index).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return "", err
@@ -1358,7 +1375,22 @@ func (g *Group) SetNX(key string, val []byte, exp time.Duration) (bool, error) {
 	if node == nil {
 		return false, errors.New("not found available redis node")
 	}
-	b, err := node.SetNX(context.Background(), key, val, exp).Result()
+	b, err := node.SetNX(g.getCtx(), key, val, exp).Result()
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return false, err
+	}
+	return b, nil
+}
+
+// SetXX key 存在时才写入, 返回是否写入
+func (g *Group) SetXX(key string, val []byte, exp time.Duration) (bool, error) {
+	log.Debugf("redis: SetXX: key %s exp %v", key, exp)
+	node := g.FindClient4Key(key)
+	if node == nil {
+		return false, errors.New("not found available redis node")
+	}
+	b, err := node.SetXX(g.getCtx(), key, val, exp).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return false, err
@@ -1366,6 +1398,15 @@ func (g *Group) SetNX(key string, val []byte, exp time.Duration) (bool, error) {
 	return b, nil
 }
 
+func (g *Group) SetJsonXX(key string, j interface{}, exp time.Duration) (bool, error) {
+	val, err := json.Marshal(j)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return false, err
+	}
+	return g.SetXX(key, val, exp)
+}
+
 func (g *Group) SetPbNX(key string, pb proto.Message, exp time.Duration) (bool, error) {
 	log.Debugf("redis: SetPbNX: key %s exp %v", key, exp)
 	val, err := proto.Marshal(pb)
@@ -1387,7 +1428,7 @@ func (g *Group) ZScore(key string, member string) (float64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	score, err := node.ZScore(context.Background(), key, member).Result()
+	score, err := node.ZScore(g.getCtx(), key, member).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -1401,7 +1442,7 @@ func (g *Group) Ttl(key string) (time.Duration, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	ttl, err := node.TTL(context.Background(), key).Result()
+	ttl, err := node.TTL(g.getCtx(), key).Result()
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return 0, err
@@ -1415,7 +1456,7 @@ func (g *Group) SetBit(key string, offset int64, val int) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	intCmd := node.SetBit(context.Background(), key, offset, val)
+	intCmd := node.SetBit(g.getCtx(), key, offset, val)
 	if intCmd.Err() != nil {
 		log.Errorf("err:%s", intCmd.Err())
 		return 0, intCmd.Err()
@@ -1429,7 +1470,7 @@ func (g *Group) GetBit(key string, offset int64) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	intCmd := node.GetBit(context.Background(), key, offset)
+	intCmd := node.GetBit(g.getCtx(), key, offset)
 	if intCmd.Err() != nil {
 		log.Errorf("err:%s", intCmd.Err())
 		return 0, intCmd.Err()
@@ -1443,7 +1484,7 @@ func (g *Group) BitCount(key string, bitCount *redis.BitCount) (int64, error) {
 	if node == nil {
 		return 0, errors.New("not found available redis node")
 	}
-	intCmd := node.BitCount(context.Background(), key, bitCount)
+	intCmd := node.BitCount(g.getCtx(), key, bitCount)
 	if intCmd.Err() != nil {
 		log.Errorf("err:%s", intCmd.Err())
 		return 0, intCmd.Err()
@@ -1458,7 +1499,7 @@ func (g *Group) FlushAll() (string, error) {
 		return "", errors.New("not found available redis node")
 	}
 
-	intCmd := node.FlushAll(context.Background())
+	intCmd := node.FlushAll(g.getCtx())
 	if intCmd.Err() != nil {
 		log.Errorf("err:%s", intCmd.Err())
 		return "", intCmd.Err()
@@ -0,0 +1,66 @@
+package bredis
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/oldbai555/micro/btrace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+)
+
+// traceHook 通过 WithContext 传入的 ctx 中有 span 时, 每个命令记录为子 span, 不记录参数
+type traceHook struct {
+	node string
+}
+
+var _ redis.Hook = (*traceHook)(nil)
+
+func newTraceHook(node string) *traceHook {
+	return &traceHook{node: node}
+}
+
+func (h *traceHook) start(ctx context.Context, name string) context.Context {
+	ctx, _ = btrace.StartChild(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemRedis,
+			semconv.DBOperationKey.String(name),
+			semconv.NetPeerNameKey.String(h.node),
+		),
+	)
+	return ctx
+}
+
+func (h *traceHook) end(ctx context.Context, err error) {
+	if errors.Is(err, redis.Nil) {
+		err = nil
+	}
+	btrace.End(trace.SpanFromContext(ctx), err)
+}
+
+func (h *traceHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return h.start(ctx, strings.ToLower(cmd.Name())), nil
+}
+
+func (h *traceHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.end(ctx, cmd.Err())
+	return nil
+}
+
+func (h *traceHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return h.start(ctx, "pipeline"), nil
+}
+
+func (h *traceHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil && !errors.Is(cmd.Err(), redis.Nil) {
+			err = cmd.Err()
+			break
+		}
+	}
+	h.end(ctx, err)
+	return nil
+}
@@ -7,14 +7,13 @@ import (
 
 var _ uctx.IUCtx = (*GrpcUCtx)(nil)
 
+// NewGrpcUCtx 以 ctx 为父 context, 截止时间, 取消信号与 context value 均以 ctx 为准
 func NewGrpcUCtx(ctx context.Context) *GrpcUCtx {
 	return &GrpcUCtx{
-		Context:  ctx,
-		BaseUCtx: uctx.NewBaseUCtx(),
+		BaseUCtx: uctx.New(ctx),
 	}
 }
 
 type GrpcUCtx struct {
-	context.Context
 	*uctx.BaseUCtx
 }
@@ -16,4 +16,5 @@ var RoundRobinDialOpts = []grpc.DialOption{
 	grpc.WithInsecure(),
 	grpc.WithBlock(),
 	grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingConfig": [{"%s":{}}]}`, roundrobin.Name)),
+	grpc.WithChainUnaryInterceptor(MetricsClient(), TraceClient(), UCtxClient()),
 }
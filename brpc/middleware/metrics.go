@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"github.com/oldbai555/micro/bprometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Metrics 服务端的请求数, 耗时与大小, 放在最外层, 避免 panic 时并发数无法回落
+func Metrics() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		end := bprometheus.GrpcServerMetrics.Begin(info.FullMethod)
+		rsp, err := handler(ctx, req)
+		end(grpcCode(err), msgSize(req), msgSize(rsp))
+		return rsp, err
+	}
+}
+
+// MetricsClient 客户端的请求数, 耗时与大小, 配合 grpc.WithChainUnaryInterceptor 使用
+func MetricsClient() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		end := bprometheus.GrpcClientMetrics.Begin(method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		rspSize := -1
+		if err == nil {
+			rspSize = msgSize(reply)
+		}
+		end(grpcCode(err), msgSize(req), rspSize)
+		return err
+	}
+}
+
+func grpcCode(err error) string {
+	if err == nil {
+		return bprometheus.CodeOK
+	}
+	return status.Code(err).String()
+}
+
+func msgSize(msg interface{}) int {
+	m, ok := msg.(proto.Message)
+	if !ok || m == nil {
+		return -1
+	}
+	return proto.Size(m)
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	"github.com/oldbai555/micro/bconst"
+	"github.com/oldbai555/micro/btrace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// mdCarrier 以 grpc metadata 作为 propagation.TextMapCarrier
+type mdCarrier metadata.MD
+
+func (c mdCarrier) Get(key string) string {
+	list := metadata.MD(c).Get(key)
+	if len(list) == 0 {
+		return ""
+	}
+	return list[0]
+}
+
+func (c mdCarrier) Set(key, val string) {
+	metadata.MD(c).Set(key, val)
+}
+
+func (c mdCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// rpcAttrs /pkg.Service/Method 拆分为 service 与 method
+func rpcAttrs(fullMethod string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{semconv.RPCSystemGRPC}
+	name := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		attrs = append(attrs, semconv.RPCServiceKey.String(name[:i]), semconv.RPCMethodKey.String(name[i+1:]))
+	}
+	return attrs
+}
+
+// Trace 服务端 span, 上游的 traceparent 与 X-GRPC-TRACE-ID 均可还原链路
+// 上游没有传 trace id 时, 以 span 的 trace id 补上, 保证日志与 trace 可以对应
+func Trace() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, ok := metadata.FromIncomingContext(ctx)
+		if ok {
+			md = md.Copy()
+		} else {
+			md = metadata.MD{}
+		}
+		ctx = otel.GetTextMapPropagator().Extract(ctx, mdCarrier(md))
+		ctx, span := btrace.Start(ctx, info.FullMethod,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(rpcAttrs(info.FullMethod)...),
+		)
+		if mdCarrier(md).Get(bconst.GrpcHeaderTraceId) == "" {
+			if traceId := btrace.TraceIdFromContext(ctx); traceId != "" {
+				md.Set(bconst.GrpcHeaderTraceId, traceId)
+				ctx = metadata.NewIncomingContext(ctx, md)
+			}
+		}
+
+		rsp, err := handler(ctx, req)
+		span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(status.Code(err))))
+		btrace.End(span, err)
+		return rsp, err
+	}
+}
+
+// TraceClient 客户端 span, 通过 metadata 传递 traceparent
+func TraceClient() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := btrace.Start(ctx, method,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(rpcAttrs(method)...),
+		)
+		md, ok := metadata.FromOutgoingContext(ctx)
+		if ok {
+			md = md.Copy()
+		} else {
+			md = metadata.MD{}
+		}
+		otel.GetTextMapPropagator().Inject(ctx, mdCarrier(md))
+		ctx = metadata.NewOutgoingContext(ctx, md)
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(status.Code(err))))
+		btrace.End(span, err)
+		return err
+	}
+}
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"context"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/micro/bconst"
+	"github.com/oldbai555/micro/blog"
+	"github.com/oldbai555/micro/uctx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// uctxTrust 可信的上游, 只有来自可信上游的 user id, corp id 与 roles 才会还原到 uctx
+type uctxTrust struct {
+	netList []*net.IPNet
+	mtls    bool
+}
+
+// UCtxTrustOption 见 TrustUCtxFrom
+type UCtxTrustOption func(t *uctxTrust)
+
+// WithTrustedCidrs 对端地址在 cidr 内时可信, 如 10.0.0.0/8, 单个 ip 视为 /32 或 /128
+func WithTrustedCidrs(cidrList ...string) UCtxTrustOption {
+	return func(t *uctxTrust) {
+		for _, cidr := range cidrList {
+			if !strings.Contains(cidr, "/") {
+				if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+					cidr += "/32"
+				} else {
+					cidr += "/128"
+				}
+			}
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				log.Errorf("err:%v", err)
+				continue
+			}
+			t.netList = append(t.netList, ipNet)
+		}
+	}
+}
+
+// WithTrustMTLS 对端提供了校验通过的客户端证书时可信
+func WithTrustMTLS() UCtxTrustOption {
+	return func(t *uctxTrust) {
+		t.mtls = true
+	}
+}
+
+var trustVal atomic.Pointer[uctxTrust]
+
+// TrustUCtxFrom 设置可信的上游, 默认不信任任何对端, 身份字段只能由本服务鉴权后写入
+// metadata 可由任意调用方伪造, 服务直接对外或与不可信的服务同网段时不要放行整个网段
+// 如: middleware.TrustUCtxFrom(middleware.WithTrustedCidrs("10.0.0.0/8"), middleware.WithTrustMTLS())
+func TrustUCtxFrom(opts ...UCtxTrustOption) {
+	t := &uctxTrust{}
+	for _, opt := range opts {
+		opt(t)
+	}
+	trustVal.Store(t)
+}
+
+// isTrustedPeer 对端是否可信
+func isTrustedPeer(ctx context.Context) bool {
+	t := trustVal.Load()
+	if t == nil {
+		return false
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	if t.mtls {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+			return true
+		}
+	}
+	if len(t.netList) == 0 || p.Addr == nil {
+		return false
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range t.netList {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// OutgoingUCtx 将 uctx 的身份字段写入 outgoing metadata, ExtInfo 与 SetExt 写入的扩展字段不透传
+func OutgoingUCtx(ctx context.Context) context.Context {
+	nCtx, err := uctx.ToUCtx(ctx)
+	if err != nil {
+		return ctx
+	}
+	kv := []string{
+		bconst.GrpcHeaderTraceId, nCtx.TraceId(),
+		bconst.GrpcHeaderDeviceId, nCtx.DeviceId(),
+		bconst.GrpcHeaderSid, nCtx.Sid(),
+		bconst.GrpcHeaderAuthType, nCtx.AuthType(),
+		bconst.GrpcHeaderAppId, nCtx.AppId(),
+		bconst.GrpcHeaderLocale, nCtx.Locale(),
+		bconst.GrpcHeaderClientIp, nCtx.ClientIp(),
+	}
+	if nCtx.UserId() != 0 {
+		kv = append(kv, bconst.GrpcHeaderUserId, strconv.FormatUint(nCtx.UserId(), 10))
+	}
+	if nCtx.CorpId() != 0 {
+		kv = append(kv, bconst.GrpcHeaderCorpId, strconv.FormatUint(uint64(nCtx.CorpId()), 10))
+	}
+	if len(nCtx.Roles()) > 0 {
+		kv = append(kv, bconst.GrpcHeaderRoles, strings.Join(nCtx.Roles(), ","))
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// IncomingUCtx 由 incoming metadata 还原 uctx
+// user id, corp id 与 roles 只在对端可信时还原, 见 TrustUCtxFrom
+func IncomingUCtx(ctx context.Context) uctx.IUCtx {
+	nCtx := uctx.New(ctx)
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nCtx
+	}
+	get := func(key string) string {
+		list := md.Get(key)
+		if len(list) == 0 {
+			return ""
+		}
+		return list[len(list)-1]
+	}
+
+	nCtx.SetTraceId(get(bconst.GrpcHeaderTraceId))
+	nCtx.SetDeviceId(get(bconst.GrpcHeaderDeviceId))
+	nCtx.SetSid(get(bconst.GrpcHeaderSid))
+	nCtx.SetAuthType(get(bconst.GrpcHeaderAuthType))
+	nCtx.SetAppId(get(bconst.GrpcHeaderAppId))
+	nCtx.SetLocale(get(bconst.GrpcHeaderLocale))
+	nCtx.SetClientIp(get(bconst.GrpcHeaderClientIp))
+	if !isTrustedPeer(ctx) {
+		if get(bconst.GrpcHeaderUserId) != "" || get(bconst.GrpcHeaderCorpId) != "" || get(bconst.GrpcHeaderRoles) != "" {
+			log.Debugf("uctx: ignore identity metadata from untrusted peer")
+		}
+		return nCtx
+	}
+	if val := get(bconst.GrpcHeaderUserId); val != "" {
+		userId, _ := strconv.ParseUint(val, 10, 64)
+		nCtx.SetUserId(userId)
+	}
+	if val := get(bconst.GrpcHeaderCorpId); val != "" {
+		corpId, _ := strconv.ParseUint(val, 10, 32)
+		nCtx.SetCorpId(uint32(corpId))
+	}
+	if val := get(bconst.GrpcHeaderRoles); val != "" {
+		nCtx.SetRoles(strings.Split(val, ","))
+	}
+	return nCtx
+}
+
+// UCtx 服务端由 metadata 还原 uctx, 业务代码通过 uctx.ToUCtx(ctx) 取出, 方法全名作为 blog 的 method 字段
+func UCtx() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(IncomingUCtx(blog.WithMethod(ctx, info.FullMethod)), req)
+	}
+}
+
+// UCtxClient 客户端透传 uctx, 配合 grpc.WithChainUnaryInterceptor 使用
+func UCtxClient() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(OutgoingUCtx(ctx), method, req, reply, cc, opts...)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/oldbai555/lbtool/log"
 	"github.com/oldbai555/lbtool/pkg/signal"
 	"github.com/oldbai555/micro/bgin"
+	"github.com/oldbai555/micro/blog"
 	"github.com/oldbai555/micro/brpc/middleware"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
@@ -18,7 +19,10 @@ import (
 )
 
 func StartH2CGrpcSrv(ctx context.Context, port uint32, registerFunc func(server *grpc.Server), interceptors ...grpc.UnaryServerInterceptor) error {
+	interceptors = append(interceptors, middleware.Metrics())
 	interceptors = append(interceptors, middleware.Recover())
+	interceptors = append(interceptors, middleware.Trace())
+	interceptors = append(interceptors, middleware.UCtx())
 	interceptors = append(interceptors, middleware.AutoValidate())
 
 	// 新建gRPC服务器实例
@@ -84,6 +88,8 @@ func NewSvr(name string, port uint32, rf RegisterFunc, interceptors ...grpc.Unar
 }
 
 func (s *Svr) StartGrpcSrv(_ context.Context) error {
+	blog.SetService(s.name)
+
 	// 单grpc模式-监听端口
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
 	if err != nil {
@@ -91,7 +97,7 @@ func (s *Svr) StartGrpcSrv(_ context.Context) error {
 		return err
 	}
 
-	var defaultInterceptors = []grpc.UnaryServerInterceptor{middleware.Recover(), middleware.AutoValidate()}
+	var defaultInterceptors = []grpc.UnaryServerInterceptor{middleware.Metrics(), middleware.Recover(), middleware.Trace(), middleware.UCtx(), middleware.AutoValidate()}
 	defaultInterceptors = append(defaultInterceptors, s.interceptors...)
 
 	// 新建gRPC服务器实例
@@ -0,0 +1,31 @@
+package bsession
+
+import (
+	"context"
+	"github.com/oldbai555/micro/bgin/gate"
+	"github.com/oldbai555/micro/uctx"
+)
+
+// CheckAuthFunc 适配 gate.CheckAuthFunc, 校验通过后 *Session 会作为 ExtInfo
+func (m *Mgr) CheckAuthFunc() gate.CheckAuthFunc {
+	return func(ctx context.Context, sid string) (interface{}, error) {
+		var deviceId string
+		if nCtx, err := uctx.ToUCtx(ctx); err == nil {
+			deviceId = nCtx.DeviceId()
+		}
+		return m.Check(sid, deviceId)
+	}
+}
+
+// CheckAuthF 适配 bcmd.Cmd.WithCheckAuthF
+func (m *Mgr) CheckAuthF() func(nCtx uctx.IUCtx) (interface{}, error) {
+	return func(nCtx uctx.IUCtx) (interface{}, error) {
+		return m.Check(nCtx.Sid(), nCtx.DeviceId())
+	}
+}
+
+// FromUCtx 取出鉴权时写入的会话
+func FromUCtx(nCtx uctx.IUCtx) (*Session, bool) {
+	s, ok := nCtx.ExtInfo().(*Session)
+	return s, ok
+}
@@ -0,0 +1,278 @@
+package bsession
+
+import (
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/lbtool/pkg/lberr"
+	"github.com/oldbai555/lbtool/utils"
+	"github.com/oldbai555/micro/baudit"
+	"github.com/oldbai555/micro/bcmd"
+	"github.com/oldbai555/micro/bredis"
+	"github.com/oldbai555/micro/uctx"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	_ bcmd.Principal    = (*Session)(nil)
+	_ baudit.IPrincipal = (*Session)(nil)
+	_ uctx.IIdentity    = (*Session)(nil)
+)
+
+var (
+	ErrSessionNotFound = lberr.NewErr(http.StatusUnauthorized, "session not found")
+	ErrDeviceNotMatch  = lberr.NewErr(http.StatusUnauthorized, "session device not match")
+)
+
+const (
+	defaultPrefix = "lb_session"
+	defaultTtl    = time.Hour * 24 * 7
+)
+
+// Session 会话信息, 鉴权通过后会作为 ExtInfo 写入 uctx
+type Session struct {
+	Sid       string            `json:"sid"`
+	UserId    uint64            `json:"user_id"`
+	CorpId    uint32            `json:"corp_id,omitempty"`
+	AppId     string            `json:"app_id,omitempty"`
+	DeviceId  string            `json:"device_id"`
+	Roles     []string          `json:"roles"`
+	Scopes    []string          `json:"scopes"`
+	Payload   map[string]string `json:"payload"`
+	CreatedAt uint32            `json:"created_at"`
+	ExpiredAt uint32            `json:"expired_at"`
+}
+
+func (s *Session) GetRoles() []string {
+	return s.Roles
+}
+
+func (s *Session) GetScopes() []string {
+	return s.Scopes
+}
+
+func (s *Session) AuditPrincipal() string {
+	return fmt.Sprintf("user:%d", s.UserId)
+}
+
+func (s *Session) GetIdentity() uctx.Identity {
+	return uctx.Identity{UserId: s.UserId, CorpId: s.CorpId, AppId: s.AppId, Roles: s.Roles}
+}
+
+type Mgr struct {
+	rds        *bredis.Group
+	prefix     string
+	ttl        time.Duration
+	sliding    bool
+	maxPerUser int
+}
+
+type Option func(*Mgr)
+
+func WithPrefix(prefix string) Option {
+	return func(m *Mgr) {
+		m.prefix = prefix
+	}
+}
+
+func WithTtl(ttl time.Duration) Option {
+	return func(m *Mgr) {
+		m.ttl = ttl
+	}
+}
+
+// WithSlidingExpiry 每次校验通过都会续期
+func WithSlidingExpiry() Option {
+	return func(m *Mgr) {
+		m.sliding = true
+	}
+}
+
+// WithMaxPerUser 限制单个用户的并发会话数, 超出时踢掉最早过期的会话
+func WithMaxPerUser(max int) Option {
+	return func(m *Mgr) {
+		m.maxPerUser = max
+	}
+}
+
+func NewMgr(rds *bredis.Group, opts ...Option) *Mgr {
+	m := &Mgr{
+		rds:    rds,
+		prefix: defaultPrefix,
+		ttl:    defaultTtl,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *Mgr) sessionKey(sid string) string {
+	return fmt.Sprintf("%s:sid:%s", m.prefix, sid)
+}
+
+func (m *Mgr) userKey(userId uint64) string {
+	return fmt.Sprintf("%s:user:%d", m.prefix, userId)
+}
+
+// Create 创建会话, sid 为空时自动生成
+func (m *Mgr) Create(s *Session) (*Session, error) {
+	now := utils.TimeNow()
+	if s.Sid == "" {
+		s.Sid = utils.GenUUID()
+	}
+	s.CreatedAt = now
+	s.ExpiredAt = now + uint32(m.ttl.Seconds())
+
+	err := m.rds.SetJson(m.sessionKey(s.Sid), s, m.ttl)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, err
+	}
+
+	err = m.addUserIndex(s)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, err
+	}
+
+	err = m.limitUserSessions(s.UserId)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get 获取会话, 不存在返回 ErrSessionNotFound
+func (m *Mgr) Get(sid string) (*Session, error) {
+	if sid == "" {
+		return nil, ErrSessionNotFound
+	}
+	var s Session
+	err := m.rds.GetJson(m.sessionKey(sid), &s)
+	if err != nil {
+		if m.rds.IsNotFound(err) {
+			return nil, ErrSessionNotFound
+		}
+		log.Errorf("err:%v", err)
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Check 校验会话, 会话绑定了设备时要求 deviceId 一致, 未带 deviceId 同样视为不一致
+func (m *Mgr) Check(sid, deviceId string) (*Session, error) {
+	s, err := m.Get(sid)
+	if err != nil {
+		return nil, err
+	}
+	if s.DeviceId != "" && s.DeviceId != deviceId {
+		return nil, ErrDeviceNotMatch
+	}
+	if m.sliding {
+		err = m.Touch(s)
+		if err != nil {
+			if err != ErrSessionNotFound {
+				log.Errorf("err:%v", err)
+			}
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Touch 续期, 只续期仍然存在的会话, 已被注销的返回 ErrSessionNotFound
+func (m *Mgr) Touch(s *Session) error {
+	s.ExpiredAt = utils.TimeNow() + uint32(m.ttl.Seconds())
+	ok, err := m.rds.SetJsonXX(m.sessionKey(s.Sid), s, m.ttl)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return err
+	}
+	if !ok {
+		return ErrSessionNotFound
+	}
+	return m.addUserIndex(s)
+}
+
+func (m *Mgr) Revoke(sid string) error {
+	s, err := m.Get(sid)
+	if err != nil {
+		if err == ErrSessionNotFound {
+			return nil
+		}
+		return err
+	}
+	err = m.rds.Del(m.sessionKey(sid))
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return err
+	}
+	_, err = m.rds.ZRem(m.userKey(s.UserId), sid)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return err
+	}
+	return nil
+}
+
+// RevokeAll 注销用户的所有会话
+func (m *Mgr) RevokeAll(userId uint64) error {
+	sidList, err := m.rds.ZRange(m.userKey(userId), 0, -1)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return err
+	}
+	for _, sid := range sidList {
+		err = m.rds.Del(m.sessionKey(sid))
+		if err != nil {
+			log.Errorf("err:%v", err)
+			return err
+		}
+	}
+	return m.rds.Del(m.userKey(userId))
+}
+
+// ListByUser 列出用户当前有效的会话 sid, 按过期时间升序
+func (m *Mgr) ListByUser(userId uint64) ([]string, error) {
+	key := m.userKey(userId)
+	_, err := m.rds.ZRemRangeByScore(key, "-inf", strconv.FormatUint(uint64(utils.TimeNow()), 10))
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, err
+	}
+	return m.rds.ZRange(key, 0, -1)
+}
+
+func (m *Mgr) addUserIndex(s *Session) error {
+	key := m.userKey(s.UserId)
+	err := m.rds.ZAdd(key, &redis.Z{Score: float64(s.ExpiredAt), Member: s.Sid})
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return err
+	}
+	return m.rds.Expire(key, m.ttl)
+}
+
+func (m *Mgr) limitUserSessions(userId uint64) error {
+	if m.maxPerUser <= 0 {
+		return nil
+	}
+	sidList, err := m.ListByUser(userId)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return err
+	}
+	for i := 0; i < len(sidList)-m.maxPerUser; i++ {
+		log.Infof("user %d exceed max sessions %d, revoke %s", userId, m.maxPerUser, sidList[i])
+		err = m.Revoke(sidList[i])
+		if err != nil {
+			log.Errorf("err:%v", err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package btrace
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/oldbai555/micro/bconst"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"math/rand"
+	"strings"
+	"sync"
+)
+
+type lbTraceIdKey struct{}
+
+// lbPropagator 与 X-LB-TRACE-ID 互通
+// X-LB-TRACE-ID 形如 uuid.uuid.uuid, 每经过一跳追加一段, 取第一段 uuid 的 128 位作为 trace id
+// 上游没有 traceparent 时, 新建的根 span 沿用该 trace id, 同一条链路在日志与 trace 中可以对应起来
+type lbPropagator struct{}
+
+var _ propagation.TextMapPropagator = lbPropagator{}
+
+func (lbPropagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || carrier.Get(bconst.GinHeaderTraceId) != "" {
+		return
+	}
+	carrier.Set(bconst.GinHeaderTraceId, FormatTraceId(sc.TraceID()))
+}
+
+func (lbPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	val := carrier.Get(bconst.GinHeaderTraceId)
+	if val == "" {
+		val = carrier.Get(bconst.GrpcHeaderTraceId)
+	}
+	traceId, ok := ParseTraceId(val)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, lbTraceIdKey{}, traceId)
+}
+
+func (lbPropagator) Fields() []string {
+	return []string{bconst.GinHeaderTraceId}
+}
+
+// FormatTraceId 按 uuid 的格式输出, 可以直接作为 X-LB-TRACE-ID
+func FormatTraceId(traceId trace.TraceID) string {
+	s := traceId.String()
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+// ParseTraceId 取 X-LB-TRACE-ID 第一段, 去掉 - 后需为 32 位十六进制
+func ParseTraceId(val string) (trace.TraceID, bool) {
+	var traceId trace.TraceID
+	if i := strings.IndexByte(val, '.'); i >= 0 {
+		val = val[:i]
+	}
+	val = strings.ReplaceAll(val, "-", "")
+	if len(val) != 32 {
+		return traceId, false
+	}
+	_, err := hex.Decode(traceId[:], []byte(val))
+	if err != nil || !traceId.IsValid() {
+		return traceId, false
+	}
+	return traceId, true
+}
+
+// TraceIdFromContext ctx 中 span 的 trace id, 按 uuid 的格式输出, 没有时返回空串
+func TraceIdFromContext(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+	return FormatTraceId(sc.TraceID())
+}
+
+// idGenerator 根 span 优先使用 lbPropagator 还原出的 trace id
+type idGenerator struct {
+	lock sync.Mutex
+	rand *rand.Rand
+}
+
+func newIdGenerator() *idGenerator {
+	var seed int64
+	_ = binary.Read(crand.Reader, binary.LittleEndian, &seed)
+	return &idGenerator{rand: rand.New(rand.NewSource(seed))}
+}
+
+func (g *idGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	traceId, ok := ctx.Value(lbTraceIdKey{}).(trace.TraceID)
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if !ok {
+		_, _ = g.rand.Read(traceId[:])
+	}
+	return traceId, g.newSpanId()
+}
+
+func (g *idGenerator) NewSpanID(ctx context.Context, traceId trace.TraceID) trace.SpanID {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.newSpanId()
+}
+
+func (g *idGenerator) newSpanId() trace.SpanID {
+	var spanId trace.SpanID
+	for !spanId.IsValid() {
+		_, _ = g.rand.Read(spanId[:])
+	}
+	return spanId
+}
@@ -0,0 +1,133 @@
+package btrace
+
+import (
+	"context"
+	"github.com/oldbai555/lbtool/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/oldbai555/micro"
+
+type config struct {
+	otlpEndpoint string
+	otlpOpts     []otlptracehttp.Option
+	exporterList []sdktrace.SpanExporter
+	syncList     []sdktrace.SpanExporter
+	sampleRatio  float64
+}
+
+type Option func(*config)
+
+// WithOtlp 通过 OTLP/HTTP 上报, endpoint 形如 otel-collector:4318
+// 如: btrace.WithOtlp("otel-collector:4318", otlptracehttp.WithInsecure())
+func WithOtlp(endpoint string, opts ...otlptracehttp.Option) Option {
+	return func(c *config) {
+		c.otlpEndpoint = endpoint
+		c.otlpOpts = opts
+	}
+}
+
+// WithExporter 批量异步上报的 exporter
+func WithExporter(exp sdktrace.SpanExporter) Option {
+	return func(c *config) {
+		c.exporterList = append(c.exporterList, exp)
+	}
+}
+
+// WithSyncExporter span 结束时同步上报, 用于测试, 配合 NewMemoryExporter 使用
+func WithSyncExporter(exp sdktrace.SpanExporter) Option {
+	return func(c *config) {
+		c.syncList = append(c.syncList, exp)
+	}
+}
+
+// WithSampleRatio 根 span 的采样比例, 默认全部采样, 子 span 跟随上游的采样结果
+func WithSampleRatio(ratio float64) Option {
+	return func(c *config) {
+		c.sampleRatio = ratio
+	}
+}
+
+// NewMemoryExporter 保存在内存中的 exporter, 测试时通过 GetSpans 断言
+func NewMemoryExporter() *tracetest.InMemoryExporter {
+	return tracetest.NewInMemoryExporter()
+}
+
+// Init 设置全局的 TracerProvider 与 propagator, 返回的函数在退出时调用, 用于上报剩余的 span
+// 未调用 Init 时各处埋点使用 otel 默认的 noop 实现, 没有额外开销
+func Init(ctx context.Context, service string, opts ...Option) (func(ctx context.Context) error, error) {
+	c := &config{sampleRatio: 1}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(service)))
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, err
+	}
+
+	tpOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithIDGenerator(newIdGenerator()),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.sampleRatio))),
+	}
+	if c.otlpEndpoint != "" {
+		exp, err := otlptracehttp.New(ctx, append([]otlptracehttp.Option{otlptracehttp.WithEndpoint(c.otlpEndpoint)}, c.otlpOpts...)...)
+		if err != nil {
+			log.Errorf("err:%v", err)
+			return nil, err
+		}
+		c.exporterList = append(c.exporterList, exp)
+	}
+	for _, exp := range c.exporterList {
+		tpOpts = append(tpOpts, sdktrace.WithBatcher(exp))
+	}
+	for _, exp := range c.syncList {
+		tpOpts = append(tpOpts, sdktrace.WithSyncer(exp))
+	}
+
+	tp := sdktrace.NewTracerProvider(tpOpts...)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(NewPropagator())
+	return tp.Shutdown, nil
+}
+
+// NewPropagator traceparent 优先, 没有时由 X-LB-TRACE-ID 还原 trace id
+func NewPropagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(lbPropagator{}, propagation.TraceContext{}, propagation.Baggage{})
+}
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start 开始一个 span, 与 Tracer().Start 相同
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// StartChild 只在 ctx 中已有 span 时开始子 span, 避免没有上下文的 redis, sql 调用产生大量孤立的根 span
+func StartChild(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(nil)
+	}
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// End 记录错误后结束 span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
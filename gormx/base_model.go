@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"github.com/oldbai555/lbtool/pkg/lberr"
 	"github.com/oldbai555/micro/core"
+	"github.com/oldbai555/micro/gormx/engine"
 	"github.com/oldbai555/micro/uctx"
 	"gorm.io/gorm"
 	"path/filepath"
 	"reflect"
 	"runtime"
 	"strconv"
+	"time"
 )
 
 const defaultLimit uint32 = 2000
@@ -17,6 +19,11 @@ const defaultLimit uint32 = 2000
 type ModelConfig struct {
 	NotFoundErrCode int32
 	Db              string
+	// TenantColumn 租户字段, 如 corp_id, 声明后查询, 更新与删除自动追加 uctx 中的租户条件
+	// 上下文中没有租户时拒绝执行, 跨租户需显式调用 WithoutTenant
+	TenantColumn string
+	// QueryTimeout 单条语句的默认超时, 0 表示只受调用方截止时间的约束
+	QueryTimeout time.Duration
 }
 
 type BaseModel[M any] struct {
@@ -61,6 +68,11 @@ func (p *BaseModel[M]) IsNotFoundErr(err error) bool {
 	return err == p.GetNotFoundErr()
 }
 
+// IsQueryCanceledErr 查询因调用方断开或超时而中止
+func (p *BaseModel[M]) IsQueryCanceledErr(err error) bool {
+	return engine.IsQueryCanceledErr(err)
+}
+
 func (p *BaseModel[M]) IsUniqueIndexConflictErr(err error) bool {
 	return lberr.GetErrCode(err) == ErrModelUniqueIndexConflict
 }
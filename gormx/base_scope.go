@@ -1,6 +1,7 @@
 package gormx
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	jsoniter "github.com/json-iterator/go"
@@ -12,6 +13,7 @@ import (
 	"github.com/oldbai555/micro/uctx"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/bytedance/sonic"
 	"google.golang.org/protobuf/proto"
@@ -37,6 +39,11 @@ type BaseScope[M any] struct {
 
 	corpId uint32
 
+	withoutTenant bool
+	tenantId      uint32 // 执行时由 applyTenant 写入
+
+	timeout time.Duration
+
 	caller string // 调用db的文件:行号.函数名
 
 	ignoreConflict bool
@@ -88,6 +95,29 @@ func (p *BaseScope[M]) WithDb(db string) *BaseScope[M] {
 	return p
 }
 
+// WithTimeout 本次查询的超时, 覆盖 ModelConfig.QueryTimeout
+func (p *BaseScope[M]) WithTimeout(timeout time.Duration) *BaseScope[M] {
+	p.timeout = timeout
+	return p
+}
+
+// queryCtx 叠加查询超时, 调用方的截止时间更早时以调用方为准
+func (p *BaseScope[M]) queryCtx(ctx uctx.IUCtx) (uctx.IUCtx, context.CancelFunc) {
+	timeout := p.timeout
+	if timeout <= 0 {
+		timeout = p.m.QueryTimeout
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	var parent context.Context = context.Background()
+	if ctx != nil {
+		parent = ctx
+	}
+	tCtx, cancel := context.WithTimeout(parent, timeout)
+	return uctx.New(tCtx), cancel
+}
+
 func (p *BaseScope[M]) WithTable(table string) *BaseScope[M] {
 	p.table = table
 	return p
@@ -183,7 +213,7 @@ func (p *BaseScope[M]) newGetModelListReq() *engine.GetModelListReq {
 	return &engine.GetModelListReq{
 		ObjType:             m.modelType,
 		Table:               p.GetTableName(),
-		Cond:                p.cond.ToString(),
+		Cond:                p.getCond(),
 		Offset:              p.offset,
 		Limit:               p.limit,
 		Fields:              p.selects,
@@ -263,13 +293,25 @@ func (p *BaseScope[M]) Unscoped(b ...bool) *BaseScope[M] {
 }
 
 func (p *BaseScope[M]) Create(ctx uctx.IUCtx, obj interface{}) error {
+	err := p.applyTenant(ctx)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return err
+	}
+	err = p.fillTenant(obj)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return err
+	}
 	j, err := toJonSkipZeroValueField(obj)
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return err
 	}
 	ormEngine := engine.GetOrmEngine()
-	rsp, err := ormEngine.InsertModel(ctx, &engine.InsertModelReq{
+	qCtx, cancel := p.queryCtx(ctx)
+	defer cancel()
+	rsp, err := ormEngine.InsertModel(qCtx, &engine.InsertModelReq{
 		ObjType:          p.m.modelType,
 		Db:               p.db,
 		Table:            p.GetTableName(),
@@ -310,9 +352,16 @@ func (p *BaseScope[M]) FirstWithResult(ctx uctx.IUCtx, obj interface{}) (SelectR
 	var res SelectResult
 	p.limit = 1
 	p.offset = 0
+	err := p.applyTenant(ctx)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return res, err
+	}
 	req := p.newGetModelListReq()
 	ormEngine := engine.GetOrmEngine()
-	rsp, err := ormEngine.GetModelList(ctx, req)
+	qCtx, cancel := p.queryCtx(ctx)
+	defer cancel()
+	rsp, err := ormEngine.GetModelList(qCtx, req)
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return res, err
@@ -350,9 +399,17 @@ func (p *BaseScope[M]) FindWithResult(ctx uctx.IUCtx, out interface{}) (SelectRe
 		return res, err
 	}
 
+	err := p.applyTenant(ctx)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return res, err
+	}
+
 	req := p.newGetModelListReq()
 	ormEngine := engine.GetOrmEngine()
-	rsp, err := ormEngine.GetModelList(ctx, req)
+	qCtx, cancel := p.queryCtx(ctx)
+	defer cancel()
+	rsp, err := ormEngine.GetModelList(qCtx, req)
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return res, err
@@ -383,10 +440,15 @@ func (p *BaseScope[M]) Delete(ctx uctx.IUCtx) (DeleteResult, error) {
 		return DeleteResult{}, nil
 	}
 	var res DeleteResult
-	cond := p.cond.ToString()
-	if cond == "" {
+	if p.cond.ToString() == "" {
 		return res, errors.New("cond empty")
 	}
+	err := p.applyTenant(ctx)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return res, err
+	}
+	cond := p.getCond()
 	ormEngine := engine.GetOrmEngine()
 	req := &engine.DelModelReq{
 		ObjType:          p.m.modelType,
@@ -400,7 +462,9 @@ func (p *BaseScope[M]) Delete(ctx uctx.IUCtx) (DeleteResult, error) {
 		Db:               p.db,
 		TrId:             p.trId,
 	}
-	rsp, err := ormEngine.DelModel(ctx, req)
+	qCtx, cancel := p.queryCtx(ctx)
+	defer cancel()
+	rsp, err := ormEngine.DelModel(qCtx, req)
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return res, err
@@ -449,11 +513,16 @@ func (p *BaseScope[M]) Update(ctx uctx.IUCtx, updateMap map[string]interface{})
 		err := errors.New("update map is empty")
 		return res, err
 	}
-	cond := p.cond.ToString()
-	if cond == "" {
+	if p.cond.ToString() == "" {
 		err := errors.New("cond is empty")
 		return res, err
 	}
+	err := p.applyTenant(ctx)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return res, err
+	}
+	cond := p.getCond()
 	buf, err := sonic.Marshal(updateMap)
 	if err != nil {
 		log.Errorf("err:%v", err)
@@ -461,7 +530,9 @@ func (p *BaseScope[M]) Update(ctx uctx.IUCtx, updateMap map[string]interface{})
 	}
 	j := string(buf)
 	ormEngine := engine.GetOrmEngine()
-	rsp, err := ormEngine.UpdateModel(ctx, &engine.UpdateModelReq{
+	qCtx, cancel := p.queryCtx(ctx)
+	defer cancel()
+	rsp, err := ormEngine.UpdateModel(qCtx, &engine.UpdateModelReq{
 		ObjType:          p.m.modelType,
 		Table:            p.GetTableName(),
 		JsonData:         j,
@@ -500,6 +571,12 @@ func (p *BaseScope[M]) BatchCreate(ctx uctx.IUCtx, chunkSize int, objList interf
 		return res, nil
 	}
 
+	err := p.applyTenant(ctx)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return res, err
+	}
+
 	if vo.Len() > chunkSize {
 		log.Warnf("batch insert len %d, too big, split to chunk with size %d",
 			vo.Len(), chunkSize)
@@ -507,10 +584,20 @@ func (p *BaseScope[M]) BatchCreate(ctx uctx.IUCtx, chunkSize int, objList interf
 	for chunkIdx := 0; chunkIdx < vo.Len(); chunkIdx += chunkSize {
 		var list []interface{}
 		for i := 0; i < chunkSize && chunkIdx+i < vo.Len(); i++ {
-			list = append(list, vo.Index(chunkIdx+i).Interface())
+			item := vo.Index(chunkIdx + i)
+			// 元素为结构体时取地址, 补齐的租户才能写回
+			if item.Kind() == reflect.Struct && item.CanAddr() {
+				item = item.Addr()
+			}
+			list = append(list, item.Interface())
 		}
 		var jsonList []string
 		for _, obj := range list {
+			err = p.fillTenant(obj)
+			if err != nil {
+				log.Errorf("err:%v", err)
+				return res, err
+			}
 			j, err := toJonSkipZeroValueField(obj)
 			if err != nil {
 				log.Errorf("err:%v", err)
@@ -519,7 +606,8 @@ func (p *BaseScope[M]) BatchCreate(ctx uctx.IUCtx, chunkSize int, objList interf
 			jsonList = append(jsonList, j)
 		}
 		ormEngine := engine.GetOrmEngine()
-		rsp, err := ormEngine.BatchInsertModel(ctx, &engine.BatchInsertModelReq{
+		qCtx, cancel := p.queryCtx(ctx)
+		rsp, err := ormEngine.BatchInsertModel(qCtx, &engine.BatchInsertModelReq{
 			ObjType:          p.m.modelType,
 			Table:            p.GetTableName(),
 			JsonDataList:     jsonList,
@@ -530,6 +618,7 @@ func (p *BaseScope[M]) BatchCreate(ctx uctx.IUCtx, chunkSize int, objList interf
 			Db:               p.db,
 			TrId:             p.trId,
 		})
+		cancel()
 		if err != nil {
 			log.Errorf("err:%v", err)
 			return res, err
@@ -560,7 +649,16 @@ func (p *BaseScope[M]) BatchCreate(ctx uctx.IUCtx, chunkSize int, objList interf
 func (p *BaseScope[M]) Save(ctx uctx.IUCtx, obj interface{}) (UpdateResult, error) {
 	var res UpdateResult
 	var j string
-	var err error
+	err := p.applyTenant(ctx)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return res, err
+	}
+	err = p.fillTenant(obj)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return res, err
+	}
 	if pb, ok := obj.(proto.Message); ok {
 		j, err = Pb2JsonDoNotSkipDefaults(pb)
 		if err != nil {
@@ -575,10 +673,12 @@ func (p *BaseScope[M]) Save(ctx uctx.IUCtx, obj interface{}) (UpdateResult, erro
 		}
 		j = string(buf)
 	}
-	cond := p.cond.ToString()
+	cond := p.getCond()
 
 	ormEngine := engine.GetOrmEngine()
-	rsp, err := ormEngine.SetModel(ctx, &engine.SetModelReq{
+	qCtx, cancel := p.queryCtx(ctx)
+	defer cancel()
+	rsp, err := ormEngine.SetModel(qCtx, &engine.SetModelReq{
 		ObjType:          p.m.modelType,
 		Table:            p.GetTableName(),
 		JsonData:         j,
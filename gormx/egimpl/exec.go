@@ -6,6 +6,7 @@ import (
 	"github.com/oldbai555/lbtool/extpkg/pie/pie"
 	"github.com/oldbai555/lbtool/log"
 	"github.com/oldbai555/lbtool/pkg/lberr"
+	"github.com/oldbai555/micro/gormx/engine"
 	"github.com/oldbai555/micro/uctx"
 	"gorm.io/gorm"
 	"strconv"
@@ -61,6 +62,16 @@ type SqlParsedResult struct {
 }
 
 func ParseSql(s string) (*SqlParsedResult, error) {
+	r, err := parseSql(s)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, err
+	}
+	return r, nil
+}
+
+// parseSql 不打印错误日志, 供每条语句都要解析的监控使用
+func parseSql(s string) (*SqlParsedResult, error) {
 	var r SqlParsedResult
 	walkNode := func(node sqlparser.SQLNode) {
 		switch v := node.(type) {
@@ -112,19 +123,22 @@ func ParseSql(s string) (*SqlParsedResult, error) {
 
 	stmt, err := sqlparser.Parse(s)
 	if err != nil {
-		log.Errorf("err:%v", err)
 		return nil, err
 	}
 
 	walkNode(stmt)
+	// 子查询不改变语句类型, 如 UPDATE ... WHERE id IN (SELECT ...) 仍为 UPDATE
+	rootTyp := r.typ
 	err = stmt.WalkSubtree(func(node sqlparser.SQLNode) (bool, error) {
 		walkNode(node)
 		return true, nil
 	})
 	if err != nil {
-		log.Errorf("err:%v", err)
 		return nil, err
 	}
+	if rootTyp != 0 {
+		r.typ = rootTyp
+	}
 	bu := sqlparser.NewTrackedBuffer(nil)
 	stmt.Format(bu)
 	r.sqlRemovedVal = bu.String()
@@ -149,8 +163,17 @@ type TraceInfo struct {
 	ClientIp  string `json:"client_ip"`
 }
 
+// withCtx 语句与 ctx 绑定, 调用方断开或超时后 mysql 端随之中止
+func withCtx(ctx uctx.IUCtx, db *gorm.DB) *gorm.DB {
+	if ctx == nil {
+		return db
+	}
+	return db.WithContext(ctx)
+}
+
 func dbExec(ctx uctx.IUCtx, db *gorm.DB, sql string, values ...interface{}) *gorm.DB {
-	res := db.Exec(sql, values...)
+	res := withCtx(ctx, db).Exec(sql, values...)
+	res.Error = engine.ToQueryErr(ctx, res.Error)
 	return res
 }
 
@@ -166,11 +189,12 @@ const (
 
 func RawQuery(ctx uctx.IUCtx, db *gorm.DB, sqlQuery string, sqlValues ...interface{}) (res *Rows, err error) {
 	var bytesRead = int64(0)
-	rows, err := db.Raw(sqlQuery, sqlValues...).Rows()
+	rows, err := withCtx(ctx, db).Raw(sqlQuery, sqlValues...).Rows()
 	if err != nil {
 		log.Errorf("err:%v", err)
-		return nil, err
+		return nil, engine.ToQueryErr(ctx, err)
 	}
+	defer rows.Close()
 	cols, err := rows.Columns()
 	if err != nil {
 		log.Errorf("err:%v", err)
@@ -191,7 +215,7 @@ func RawQuery(ctx uctx.IUCtx, db *gorm.DB, sqlQuery string, sqlValues ...interfa
 	for rows.Next() {
 		err = rows.Scan(scanArgs...)
 		if err != nil {
-			return
+			return nil, engine.ToQueryErr(ctx, err)
 		}
 		var row []string
 		for _, v := range values {
@@ -210,6 +234,11 @@ func RawQuery(ctx uctx.IUCtx, db *gorm.DB, sqlQuery string, sqlValues ...interfa
 
 		res.rows = append(res.rows, row)
 	}
+	err = rows.Err()
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, engine.ToQueryErr(ctx, err)
+	}
 	return res, nil
 }
 
@@ -186,7 +186,6 @@ func NewGormEngine(dsn string) *GormEngine {
 		trMap:      make(map[string]*trInfo),
 	}
 	ormLog := NewOrmLog(time.Second * 5)
-	ormLog.skipCall = 11
 	g.db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
 		NamingStrategy: schema.NamingStrategy{
 			SingularTable: true,  // 是否单表，命名是否复数
@@ -201,6 +200,12 @@ func NewGormEngine(dsn string) *GormEngine {
 		panic(err)
 	}
 
+	// 监控与链路按 Statement 取语句信息
+	err = registerStmtCallback(g.db)
+	if err != nil {
+		panic(err)
+	}
+
 	// 获取通用数据库对象 sql.DB ，然后使用其提供的功能
 	sqlDB, err := g.db.DB()
 
@@ -212,5 +217,8 @@ func NewGormEngine(dsn string) *GormEngine {
 
 	// SetConnMaxLifetime 设置了连接可复用的最大时间。
 	sqlDB.SetConnMaxLifetime(time.Hour)
+
+	// 连接池状态
+	registerDBStats(sqlDB, dsn)
 	return g
 }
@@ -4,7 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/micro/blog"
 	"gorm.io/gorm/logger"
 	"time"
 )
@@ -31,6 +31,9 @@ const (
 	traceErrStr  = "%s %s [%.3fms] [rows:%v]"
 )
 
+// ormCallerPrefixList sql 日志的调用位置跳过 gorm 与 gormx 自身
+var ormCallerPrefixList = []string{"gorm.io/", "github.com/oldbai555/micro/gormx"}
+
 // NewOrmLog initialize logger
 func NewOrmLog(slowThreshold time.Duration) *ormlog {
 	return &ormlog{
@@ -38,56 +41,48 @@ func NewOrmLog(slowThreshold time.Duration) *ormlog {
 	}
 }
 
+// ormlog 通过 blog 输出, 带上 ctx 中的 trace id 等字段, 等级由 gormx/egimpl 包的等级控制
 type ormlog struct {
 	slowThreshold time.Duration
-	skipCall      int
 }
 
 func (l *ormlog) LogMode(level logger.LogLevel) logger.Interface {
 	return l
 }
 
+func (l ormlog) logger(ctx context.Context) *blog.Logger {
+	return blog.FromCtx(ctx).WithCallerSkip(ormCallerPrefixList...)
+}
+
 func (l ormlog) Info(ctx context.Context, msg string, data ...interface{}) {
-	log.GetLogger().SetSkipCall(l.skipCall)
-	defer func() {
-		log.GetLogger().SetSkipCall(log.DefaultSkipCall)
-	}()
-	log.Debugf(msg, data...)
+	l.logger(ctx).Debugf(msg, data...)
 }
 
 func (l ormlog) Warn(ctx context.Context, msg string, data ...interface{}) {
-	log.GetLogger().SetSkipCall(l.skipCall)
-	defer func() {
-		log.GetLogger().SetSkipCall(log.DefaultSkipCall)
-	}()
-	log.Warnf(msg, data...)
+	l.logger(ctx).Warnf(msg, data...)
 }
 
 func (l ormlog) Error(ctx context.Context, msg string, data ...interface{}) {
-	log.GetLogger().SetSkipCall(l.skipCall)
-	defer func() {
-		log.GetLogger().SetSkipCall(log.DefaultSkipCall)
-	}()
-	log.Errorf(msg, data...)
+	l.logger(ctx).Errorf(msg, data...)
 }
 
 func (l ormlog) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
-	log.GetLogger().SetSkipCall(l.skipCall)
-	defer func() {
-		log.GetLogger().SetSkipCall(log.DefaultSkipCall)
-	}()
 	elapsed := time.Since(begin)
+	sql, rows := fc()
+	isErr := err != nil && (!errors.Is(err, logger.ErrRecordNotFound))
+	isSlow := elapsed > l.slowThreshold && l.slowThreshold != 0
+	info := getSqlInfo(ctx, sql)
+	observeSql(info, rows, elapsed, isErr, isSlow)
+	traceSql(ctx, info, begin, elapsed, rows, isErr, err)
 	switch {
-	case err != nil && (!errors.Is(err, logger.ErrRecordNotFound)):
-		sql, rows := fc()
+	case isErr:
 		//utils.FileWithLineNum()
 		if rows == -1 {
 			l.Error(ctx, traceErrStr, err, sql, float64(elapsed.Nanoseconds())/1e6, "-")
 		} else {
-			l.Error(ctx, traceErrStr, err, sql, float64(elapsed.Nanoseconds())/1e6, rows, sql)
+			l.Error(ctx, traceErrStr, err, sql, float64(elapsed.Nanoseconds())/1e6, rows)
 		}
-	case elapsed > l.slowThreshold && l.slowThreshold != 0:
-		sql, rows := fc()
+	case isSlow:
 		slowLog := fmt.Sprintf("SLOW SQL >= %v", l.slowThreshold)
 		if rows == -1 {
 			l.Warn(ctx, traceWarnStr, slowLog, sql, float64(elapsed.Nanoseconds())/1e6, "-")
@@ -95,7 +90,6 @@ func (l ormlog) Trace(ctx context.Context, begin time.Time, fc func() (string, i
 			l.Warn(ctx, traceWarnStr, slowLog, sql, float64(elapsed.Nanoseconds())/1e6, rows)
 		}
 	default:
-		sql, rows := fc()
 		if rows == -1 {
 			l.Info(ctx, traceStr, sql, float64(elapsed.Nanoseconds())/1e6, "-")
 		} else {
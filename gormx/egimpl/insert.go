@@ -99,8 +99,8 @@ func (g *GormEngine) InsertModel(ctx uctx.IUCtx, req *engine.InsertModelReq) (*e
 
 	// 执行原生SQL貌似拿插入的ID有点非常的麻烦
 	db := g.GetDB(req.TrId)
-	res := db.Table(quoteName(req.Table)).Create(j)
-	err = res.Error
+	res := withCtx(ctx, db).Table(quoteName(req.Table)).Create(j)
+	err = engine.ToQueryErr(ctx, res.Error)
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return nil, err
@@ -184,8 +184,8 @@ func (g *GormEngine) BatchInsertModel(ctx uctx.IUCtx, req *engine.BatchInsertMod
 	}
 
 	db := g.GetDB(req.TrId)
-	res := db.Table(quoteName(req.Table)).CreateInBatches(resList, len(resList))
-	err = res.Error
+	res := withCtx(ctx, db).Table(quoteName(req.Table)).CreateInBatches(resList, len(resList))
+	err = engine.ToQueryErr(ctx, res.Error)
 	if err != nil {
 		log.Errorf("err:%v", err)
 		return nil, err
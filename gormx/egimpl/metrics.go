@@ -0,0 +1,162 @@
+package egimpl
+
+import (
+	"context"
+	"database/sql"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/micro/bprometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	unknownTable = "unknown"
+	// maxSqlInfoCache 缓存的语句数上限, 拼接了参数的 Raw 语句过多时不再缓存
+	maxSqlInfoCache = 4096
+)
+
+var (
+	// sqlDurationHistogram 语句耗时, rows 为影响或返回行数所在的区间
+	sqlDurationHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "lb",
+		Subsystem: "orm",
+		Name:      "query_duration_seconds",
+		Help:      "SQL latency in seconds, partitioned by statement type, table and rows range.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"type", "table", "rows"})
+
+	// sqlErrCounter 执行失败的语句数, 不包括 record not found
+	sqlErrCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lb",
+		Subsystem: "orm",
+		Name:      "query_errors_total",
+		Help:      "Number of failed SQL statements, partitioned by statement type and table.",
+	}, []string{"type", "table"})
+
+	// slowSqlCounter 超过 slowThreshold 的语句数
+	slowSqlCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lb",
+		Subsystem: "orm",
+		Name:      "slow_queries_total",
+		Help:      "Number of SQL statements slower than the slow threshold, partitioned by statement type and table.",
+	}, []string{"type", "table"})
+)
+
+func init() {
+	bprometheus.Register(sqlDurationHistogram, sqlErrCounter, slowSqlCounter)
+}
+
+// Type 语句类型, 如 SELECT, 无法识别时为 OTHER
+func (r *SqlParsedResult) Type() string {
+	return r.typ.String()
+}
+
+// TableList 语句涉及的表
+func (r *SqlParsedResult) TableList() []string {
+	return r.tableList
+}
+
+// sqlInfo 监控与链路使用的语句信息, 每条语句只解析一次
+type sqlInfo struct {
+	typ   string
+	table string
+	stmt  string // 参数替换为 ? 的语句, 解析失败时为空
+}
+
+type stmtKey struct{}
+
+// registerStmtCallback 执行前把 Statement 放入 ctx, Trace 据此取参数未替换的语句与表名
+// gorm 在全部回调执行后以 Statement.Context 调用 Logger.Trace
+func registerStmtCallback(db *gorm.DB) error {
+	f := func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		db.Statement.Context = context.WithValue(ctx, stmtKey{}, db.Statement)
+	}
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("*").Register("lb:stmt_ctx", f),
+		cb.Query().Before("*").Register("lb:stmt_ctx", f),
+		cb.Update().Before("*").Register("lb:stmt_ctx", f),
+		cb.Delete().Before("*").Register("lb:stmt_ctx", f),
+		cb.Row().Before("*").Register("lb:stmt_ctx", f),
+		cb.Raw().Before("*").Register("lb:stmt_ctx", f),
+	} {
+		if err != nil {
+			log.Errorf("err:%v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	sqlInfoCache     sync.Map // 参数未替换的语句 -> *sqlInfo
+	sqlInfoCacheSize int64
+)
+
+// getSqlInfo 以参数未替换的语句为键缓存解析结果, 同一条语句只解析一次
+// ctx 中没有 Statement 时解析参数替换后的语句
+func getSqlInfo(ctx context.Context, s string) *sqlInfo {
+	stmt, ok := ctx.Value(stmtKey{}).(*gorm.Statement)
+	if !ok || stmt.SQL.Len() == 0 {
+		return newSqlInfo(s)
+	}
+	key := stmt.SQL.String()
+	if v, ok := sqlInfoCache.Load(key); ok {
+		return v.(*sqlInfo)
+	}
+	info := newSqlInfo(key)
+	if info.table == unknownTable && stmt.Table != "" {
+		info.table = stmt.Table
+	}
+	if atomic.AddInt64(&sqlInfoCacheSize, 1) <= maxSqlInfoCache {
+		sqlInfoCache.Store(key, info)
+	}
+	return info
+}
+
+// newSqlInfo 解析失败时 typ 为 OTHER, table 为 unknown, 多表时按名称排序后以逗号拼接
+func newSqlInfo(s string) *sqlInfo {
+	r, err := parseSql(s)
+	if err != nil {
+		return &sqlInfo{typ: sqlExecType(0).String(), table: unknownTable}
+	}
+	info := &sqlInfo{typ: r.Type(), table: unknownTable, stmt: r.sqlRemovedVal}
+	if len(r.tableList) > 0 {
+		tableList := append([]string{}, r.tableList...)
+		sort.Strings(tableList)
+		info.table = strings.Join(tableList, ",")
+	}
+	return info
+}
+
+func observeSql(info *sqlInfo, rows int64, elapsed time.Duration, isErr, isSlow bool) {
+	sqlDurationHistogram.WithLabelValues(info.typ, info.table, toRowsRangeStr(int(rows))).Observe(elapsed.Seconds())
+	if isErr {
+		sqlErrCounter.WithLabelValues(info.typ, info.table).Inc()
+	}
+	if isSlow {
+		slowSqlCounter.WithLabelValues(info.typ, info.table).Inc()
+	}
+}
+
+// registerDBStats 连接池的 sql.DB.Stats, db 标签取 dsn 中的库名, 同名库只注册一次
+// 与框架的其他指标一样注册到 bprometheus 配置的 Registry
+func registerDBStats(sqlDB *sql.DB, dsn string) {
+	dbName := unknownTable
+	cfg, err := gomysql.ParseDSN(dsn)
+	if err == nil && cfg.DBName != "" {
+		dbName = cfg.DBName
+	}
+	bprometheus.Register(collectors.NewDBStatsCollector(sqlDB, dbName))
+}
@@ -0,0 +1,32 @@
+package egimpl
+
+import (
+	"context"
+	"github.com/oldbai555/micro/btrace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// traceSql 语句执行完后补记子 span, 起止时间与实际执行一致, 语句中的参数已替换为 ?
+// record not found 不视为错误
+func traceSql(ctx context.Context, info *sqlInfo, begin time.Time, elapsed time.Duration, rows int64, isErr bool, err error) {
+	_, span := btrace.StartChild(ctx, info.typ+" "+info.table,
+		trace.WithTimestamp(begin),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemMySQL,
+			semconv.DBOperationKey.String(info.typ),
+			semconv.DBSQLTableKey.String(info.table),
+			semconv.DBStatementKey.String(info.stmt),
+			attribute.Int64("db.rows_affected", rows),
+		),
+	)
+	if isErr {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(begin.Add(elapsed)))
+}
@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// ErrQueryCanceled 调用方已断开, 与 nginx 的 499 保持一致
+	ErrQueryCanceled = 499
+	// ErrQueryTimeout 超过了调用方的截止时间或模型的查询超时
+	ErrQueryTimeout = http.StatusGatewayTimeout
+)
+
+// QueryCanceledErr 查询因 context 取消或超时而中止
+// 实现了 HttpStatus, 网关据此响应 499 / 504
+type QueryCanceledErr struct {
+	Timeout bool
+	cause   error
+}
+
+func (e *QueryCanceledErr) Error() string {
+	if e.Timeout {
+		return fmt.Sprintf("query timeout: %v", e.cause)
+	}
+	return fmt.Sprintf("query canceled: %v", e.cause)
+}
+
+func (e *QueryCanceledErr) Unwrap() error {
+	return e.cause
+}
+
+func (e *QueryCanceledErr) Code() int32 {
+	return int32(e.HttpStatus())
+}
+
+func (e *QueryCanceledErr) Message() string {
+	return e.Error()
+}
+
+func (e *QueryCanceledErr) HttpStatus() int {
+	if e.Timeout {
+		return ErrQueryTimeout
+	}
+	return ErrQueryCanceled
+}
+
+// IsQueryCanceledErr 包括取消与超时
+func IsQueryCanceledErr(err error) bool {
+	var e *QueryCanceledErr
+	return errors.As(err, &e)
+}
+
+// ToQueryErr ctx 已取消或超时的情况下, 将驱动返回的错误转成 QueryCanceledErr
+func ToQueryErr(ctx context.Context, err error) error {
+	if err == nil || IsQueryCanceledErr(err) {
+		return err
+	}
+	var ctxErr error
+	if ctx != nil {
+		ctxErr = ctx.Err()
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctxErr, context.DeadlineExceeded):
+		return &QueryCanceledErr{Timeout: true, cause: err}
+	case errors.Is(err, context.Canceled) || errors.Is(ctxErr, context.Canceled):
+		return &QueryCanceledErr{cause: err}
+	}
+	return err
+}
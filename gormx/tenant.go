@@ -0,0 +1,150 @@
+package gormx
+
+import (
+	"fmt"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/lbtool/pkg/lberr"
+	"github.com/oldbai555/lbtool/utils"
+	"github.com/oldbai555/micro/baudit"
+	"github.com/oldbai555/micro/uctx"
+	"reflect"
+	"strings"
+)
+
+// WithoutTenant 跳过租户隔离, 仅用于后台任务等跨租户的场景, 每次执行都会记录审计日志
+func (p *BaseScope[M]) WithoutTenant() *BaseScope[M] {
+	p.withoutTenant = true
+	return p
+}
+
+func (p *BaseModel[M]) WithoutTenant() *BaseScope[M] {
+	return p.NewBaseScope().WithoutTenant()
+}
+
+// IsTenantRequiredErr 声明了租户字段, 但上下文中没有租户
+func IsTenantRequiredErr(err error) bool {
+	return lberr.GetErrCode(err) == ErrModelTenantRequired
+}
+
+// applyTenant 执行前从 uctx 取出租户, 未声明 TenantColumn 的模型不做处理
+func (p *BaseScope[M]) applyTenant(ctx uctx.IUCtx) error {
+	column := p.m.TenantColumn
+	if column == "" {
+		return nil
+	}
+	if p.withoutTenant {
+		p.auditTenantBypass(ctx)
+		p.tenantId = 0
+		return nil
+	}
+	if ctx == nil || ctx.CorpId() == 0 {
+		return lberr.NewErr(ErrModelTenantRequired, "tenant required for table %s", p.GetTableName())
+	}
+	p.tenantId = ctx.CorpId()
+	if p.corpId == 0 {
+		p.corpId = p.tenantId
+	}
+	return nil
+}
+
+// auditTenantBypass 跨租户的执行记录 baudit 事件, 调用方由 ExtInfo 的 IPrincipal 给出
+func (p *BaseScope[M]) auditTenantBypass(ctx uctx.IUCtx) {
+	if ctx == nil {
+		ctx = uctx.NewBaseUCtx()
+	}
+	audit := baudit.Start(ctx, p.GetTableName())
+	audit.SetEvent(baudit.EventTenantBypass)
+	audit.End(&baudit.CmdOption{SkipBody: true}, nil, nil, nil)
+	log.Debugf("tenant bypass table:%s caller:%s", p.GetTableName(), p.caller)
+}
+
+// getCond 在用户的条件之外追加租户条件
+func (p *BaseScope[M]) getCond() string {
+	cond := p.cond.ToString()
+	if p.tenantId == 0 {
+		return cond
+	}
+	column := quoteFieldName(p.m.TenantColumn)
+	if p.cond.tablePrefix != "" {
+		column = p.cond.tablePrefix + "." + p.m.TenantColumn
+	}
+	tenantCond := fmt.Sprintf("(%s = %d)", column, p.tenantId)
+	if cond == "" {
+		return tenantCond
+	}
+	return fmt.Sprintf("%s AND (%s)", tenantCond, cond)
+}
+
+// fillTenant 写入时以上下文的租户覆盖租户字段, 调用方填写的值不可信
+func (p *BaseScope[M]) fillTenant(obj interface{}) error {
+	if p.tenantId == 0 {
+		return nil
+	}
+	column := p.m.TenantColumn
+	if m, ok := obj.(map[string]interface{}); ok {
+		if val, ok := m[column]; ok && fmt.Sprint(val) != fmt.Sprint(p.tenantId) {
+			p.warnTenantOverwrite(val)
+		}
+		m[column] = p.tenantId
+		return nil
+	}
+
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	// 传值时 reflect 拿到的是副本, 补齐的租户不会写入, 直接拒绝
+	if !v.CanAddr() {
+		return lberr.NewErr(ErrModelTenantNotPointer, "table %s has tenant column %s, pass a pointer of %s instead of a value", p.GetTableName(), column, v.Type())
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = utils.Camel2UnderScore(field.Name)
+		}
+		if name != column {
+			continue
+		}
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if f.Uint() == uint64(p.tenantId) {
+				return nil
+			}
+			if !f.CanSet() {
+				return p.newTenantMismatchErr(f.Uint())
+			}
+			if f.Uint() != 0 {
+				p.warnTenantOverwrite(f.Uint())
+			}
+			f.SetUint(uint64(p.tenantId))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if f.Int() == int64(p.tenantId) {
+				return nil
+			}
+			if !f.CanSet() {
+				return p.newTenantMismatchErr(f.Int())
+			}
+			if f.Int() != 0 {
+				p.warnTenantOverwrite(f.Int())
+			}
+			f.SetInt(int64(p.tenantId))
+		}
+		return nil
+	}
+	return nil
+}
+
+func (p *BaseScope[M]) warnTenantOverwrite(val interface{}) {
+	log.Warnf("%s %v of table %s is overwritten by tenant %d, caller:%s", p.m.TenantColumn, val, p.GetTableName(), p.tenantId, p.caller)
+}
+
+// newTenantMismatchErr 租户字段无法写入, 如未导出的字段
+func (p *BaseScope[M]) newTenantMismatchErr(val interface{}) error {
+	return lberr.NewErr(ErrModelTenantMismatch, "%s %v of table %s not match tenant %d", p.m.TenantColumn, val, p.GetTableName(), p.tenantId)
+}
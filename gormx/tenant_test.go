@@ -0,0 +1,187 @@
+package gormx
+
+import (
+	"github.com/oldbai555/lbtool/pkg/lberr"
+	"github.com/oldbai555/micro/baudit"
+	"github.com/oldbai555/micro/gormx/engine"
+	"github.com/oldbai555/micro/uctx"
+	"strings"
+	"testing"
+)
+
+type tenantOrder struct {
+	Id     uint64 `json:"id"`
+	CorpId uint32 `json:"corp_id"`
+	Name   string `json:"name"`
+}
+
+func (o *tenantOrder) TableName() string {
+	return "order"
+}
+
+// fakeEngine 记录发往引擎的请求
+type fakeEngine struct {
+	engine.IOrmEngine
+	listReq   *engine.GetModelListReq
+	insertReq *engine.InsertModelReq
+	delReq    *engine.DelModelReq
+	updateReq *engine.UpdateModelReq
+}
+
+func (e *fakeEngine) GetModelList(ctx uctx.IUCtx, req *engine.GetModelListReq) (*engine.GetModelListRsp, error) {
+	e.listReq = req
+	return &engine.GetModelListRsp{}, nil
+}
+
+func (e *fakeEngine) InsertModel(ctx uctx.IUCtx, req *engine.InsertModelReq) (*engine.InsertModelRsp, error) {
+	e.insertReq = req
+	return &engine.InsertModelRsp{}, nil
+}
+
+func (e *fakeEngine) DelModel(ctx uctx.IUCtx, req *engine.DelModelReq) (*engine.DelModelRsp, error) {
+	e.delReq = req
+	return &engine.DelModelRsp{}, nil
+}
+
+func (e *fakeEngine) UpdateModel(ctx uctx.IUCtx, req *engine.UpdateModelReq) (*engine.UpdateModelRsp, error) {
+	e.updateReq = req
+	return &engine.UpdateModelRsp{}, nil
+}
+
+type recordSink struct {
+	recList []*baudit.Record
+}
+
+func (s *recordSink) Write(rec *baudit.Record) {
+	s.recList = append(s.recList, rec)
+}
+
+type testPrincipal string
+
+func (p testPrincipal) AuditPrincipal() string {
+	return string(p)
+}
+
+func newTenantTest(t *testing.T) (*BaseModel[*tenantOrder], *fakeEngine, *uctx.BaseUCtx) {
+	e := &fakeEngine{}
+	engine.SetOrmEngine(e)
+	t.Cleanup(func() { engine.SetOrmEngine(nil) })
+
+	ctx := uctx.NewBaseUCtx()
+	ctx.SetCorpId(7)
+	return NewBaseModel[*tenantOrder](ModelConfig{TenantColumn: "corp_id"}), e, ctx
+}
+
+const tenantCond = "(`corp_id` = 7) AND ((`id` = 1))"
+
+func TestTenantCond(t *testing.T) {
+	m, e, ctx := newTenantTest(t)
+
+	_, err := m.NewBaseScope().Where("id", 1).First(ctx)
+	if !m.IsNotFoundErr(err) {
+		t.Fatalf("first: %v", err)
+	}
+	if e.listReq.Cond != tenantCond {
+		t.Errorf("first cond: %s", e.listReq.Cond)
+	}
+
+	e.listReq = nil
+	_, err = m.NewBaseScope().Find(ctx)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if e.listReq.Cond != "(`corp_id` = 7)" {
+		t.Errorf("find cond: %s", e.listReq.Cond)
+	}
+
+	_, err = m.NewBaseScope().Where("id", 1).Update(ctx, map[string]interface{}{"name": "a"})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if e.updateReq.Cond != tenantCond {
+		t.Errorf("update cond: %s", e.updateReq.Cond)
+	}
+
+	_, err = m.NewBaseScope().Where("id", 1).Delete(ctx)
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if e.delReq.Cond != tenantCond {
+		t.Errorf("delete cond: %s", e.delReq.Cond)
+	}
+}
+
+func TestTenantRequired(t *testing.T) {
+	m, e, _ := newTenantTest(t)
+
+	_, err := m.NewBaseScope().Where("id", 1).Delete(uctx.NewBaseUCtx())
+	if !IsTenantRequiredErr(err) {
+		t.Fatalf("delete without tenant: %v", err)
+	}
+	if e.delReq != nil {
+		t.Fatal("request should not reach the engine")
+	}
+}
+
+func TestFillTenant(t *testing.T) {
+	m, e, ctx := newTenantTest(t)
+
+	obj := map[string]interface{}{"name": "a", "corp_id": 8}
+	err := m.NewBaseScope().Create(ctx, obj)
+	if err != nil {
+		t.Fatalf("create map: %v", err)
+	}
+	if obj["corp_id"] != uint32(7) || !strings.Contains(e.insertReq.JsonData, `"corp_id":7`) {
+		t.Errorf("create map: %v, json %s", obj["corp_id"], e.insertReq.JsonData)
+	}
+
+	order := &tenantOrder{Name: "a", CorpId: 8}
+	err = m.NewBaseScope().Create(ctx, order)
+	if err != nil {
+		t.Fatalf("create struct: %v", err)
+	}
+	if order.CorpId != 7 || !strings.Contains(e.insertReq.JsonData, `"corp_id":7`) {
+		t.Errorf("create struct: %d, json %s", order.CorpId, e.insertReq.JsonData)
+	}
+
+	// 缺省时补齐
+	order = &tenantOrder{Name: "a"}
+	err = m.NewBaseScope().Create(ctx, order)
+	if err != nil {
+		t.Fatalf("create struct: %v", err)
+	}
+	if order.CorpId != 7 {
+		t.Errorf("create struct without tenant: %d", order.CorpId)
+	}
+
+	err = m.NewBaseScope().Create(ctx, tenantOrder{Name: "a"})
+	if lberr.GetErrCode(err) != ErrModelTenantNotPointer {
+		t.Errorf("create value: %v", err)
+	}
+}
+
+func TestWithoutTenantAudit(t *testing.T) {
+	m, e, _ := newTenantTest(t)
+
+	sink := &recordSink{}
+	old := baudit.Default()
+	baudit.SetDefault(baudit.NewAuditor(baudit.WithSink(sink), baudit.WithSampleRate(0)))
+	defer baudit.SetDefault(old)
+
+	ctx := uctx.NewBaseUCtx()
+	ctx.SetExtInfo(testPrincipal("admin"))
+	_, err := m.WithoutTenant().Where("id", 1).Delete(ctx)
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if e.delReq.Cond != "(`id` = 1)" {
+		t.Errorf("delete cond: %s", e.delReq.Cond)
+	}
+	if len(sink.recList) != 1 {
+		t.Fatalf("audit records: %d", len(sink.recList))
+	}
+	rec := sink.recList[0]
+	if rec.Event != baudit.EventTenantBypass || rec.Path != "order" || rec.Principal != "admin" {
+		t.Errorf("audit record: %+v", rec)
+	}
+}
@@ -16,6 +16,9 @@ const (
 
 const (
 	ErrModelUniqueIndexConflict = 10001
+	ErrModelTenantRequired      = 10002
+	ErrModelTenantMismatch      = 10003
+	ErrModelTenantNotPointer    = 10004 // 声明了租户字段的模型, 写入时需要传指针才能补齐租户
 )
 
 func rowsJsonToPb(rowsJson string, obj interface{}) error {
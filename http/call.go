@@ -0,0 +1,177 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/micro/bcmd"
+	"google.golang.org/protobuf/proto"
+	"reflect"
+)
+
+// Call 类型化的调用, 响应类型在编译期确定
+// 如: rsp, err := http.Call[user.GetUserReq, user.GetUserRsp](ctx, c, "user", "/user/get", http.MethodPost, req)
+func Call[Req any, Rsp any, PReq interface {
+	*Req
+	proto.Message
+}, PRsp interface {
+	*Rsp
+	proto.Message
+}](ctx context.Context, c *Client, srv, path, method string, req PReq) (PRsp, error) {
+	rsp := PRsp(new(Rsp))
+	err := c.Do(ctx, srv, path, method, req, rsp)
+	if err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+// cmdRoute 命令的远端路由
+type cmdRoute struct {
+	srv        string
+	path       string
+	method     string
+	idempotent bool
+}
+
+// CmdClient 由命令列表在运行时生成的客户端, 按函数引用选择路由
+// 服务端与调用方共用同一份 []*bcmd.Cmd 声明, 不需要代码生成
+type CmdClient struct {
+	c        *Client
+	routeMap map[string]*cmdRoute
+	// nameMap 由 reflect 方法值生成的命令 (如 gate.CmdListFromServiceDesc) 取不到函数名, 按 FuncName 查找
+	nameMap map[string][]*cmdRoute
+}
+
+// NewCmdClient cmd 未声明 Server 时使用 srv, 流式命令不支持调用, 直接跳过
+// 同一个函数注册了多个路由时返回错误
+// 由 gate.CmdListFromServiceDesc 生成的命令按方法名匹配, 也可以通过 CallMethod 按 /{Server}/{FuncName} 调用
+func NewCmdClient(srv string, cmdList []*bcmd.Cmd, opts ...Option) (*CmdClient, error) {
+	c := &CmdClient{
+		c:        NewClient(opts...),
+		routeMap: map[string]*cmdRoute{},
+		nameMap:  map[string][]*cmdRoute{},
+	}
+	for _, cmd := range cmdList {
+		if cmd.IsStream() {
+			continue
+		}
+		err := cmd.Validate()
+		if err != nil {
+			log.Errorf("err:%v", err)
+			return nil, err
+		}
+		route := &cmdRoute{
+			srv:    cmd.Server,
+			path:   cmd.VersionedPath(),
+			method: cmd.GetApiMethod(),
+			// 声明了幂等的命令由网关按 Idempotency-Key 去重, 可以安全地重试
+			idempotent: cmd.IsIdempotent(),
+		}
+		if route.srv == "" {
+			route.srv = srv
+		}
+
+		key := bcmd.FuncKey(cmd.GRpcFunc)
+		if key == "" {
+			if cmd.FuncName == "" {
+				return nil, fmt.Errorf("cmd %s: can not resolve func", cmd.Path)
+			}
+			c.nameMap[cmd.FuncName] = append(c.nameMap[cmd.FuncName], route)
+			key = genFullMethod(route.srv, cmd.FuncName)
+		}
+		if old, ok := c.routeMap[key]; ok {
+			return nil, fmt.Errorf("cmd %s: func %s already bound to %s", cmd.VersionedPath(), key, old.path)
+		}
+		c.routeMap[key] = route
+	}
+	return c, nil
+}
+
+// genFullMethod 与 grpc 的方法全名一致, 如 /user.UserService/GetUser
+func genFullMethod(srv, funcName string) string {
+	return "/" + srv + "/" + funcName
+}
+
+func (r *cmdRoute) withCtx(ctx context.Context) context.Context {
+	if r.idempotent && ctx != nil {
+		return MarkIdempotent(ctx)
+	}
+	return ctx
+}
+
+// Client 底层的 Client, 用于调用不在命令列表中的路由
+func (c *CmdClient) Client() *Client {
+	return c.c
+}
+
+// getRoute 先按函数全名查找, 找不到时按方法名匹配由 reflect 方法值生成的命令
+func (c *CmdClient) getRoute(fn interface{}) (*cmdRoute, error) {
+	key := bcmd.FuncKey(fn)
+	if key == "" {
+		return nil, fmt.Errorf("can not resolve func %T, pass a method value like svc.GetUser", fn)
+	}
+	if route, ok := c.routeMap[key]; ok {
+		return route, nil
+	}
+	name := bcmd.FuncMethodName(key)
+	list := c.nameMap[name]
+	switch len(list) {
+	case 0:
+		return nil, fmt.Errorf("not found cmd for func %q", key)
+	case 1:
+		return list[0], nil
+	}
+	return nil, fmt.Errorf("func %q matches %d cmds named %s, use CallMethod instead", key, len(list), name)
+}
+
+// CallMethod 按方法全名调用, 如 /user.UserService/GetUser, 响应写入 out
+func (c *CmdClient) CallMethod(ctx context.Context, fullMethod string, req, out proto.Message) error {
+	route, ok := c.routeMap[fullMethod]
+	if !ok {
+		err := fmt.Errorf("not found cmd for method %q", fullMethod)
+		log.Errorf("err:%v", err)
+		return err
+	}
+	return c.c.Do(route.withCtx(ctx), route.srv, route.path, route.method, req, out)
+}
+
+// Invoke 按函数引用调用, fn 的签名为 func(context.Context, *Req) (*Rsp, error), 返回 *Rsp
+// 如: rsp, err := client.Invoke(ctx, svc.GetUser, req)
+// 需要编译期的类型检查时使用 http.Invoke
+func (c *CmdClient) Invoke(ctx context.Context, fn interface{}, req proto.Message) (proto.Message, error) {
+	route, err := c.getRoute(fn)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, err
+	}
+	t := reflect.TypeOf(fn)
+	if t.NumOut() != 2 || t.Out(0).Kind() != reflect.Ptr || !t.Out(0).Implements(protoType) {
+		return nil, fmt.Errorf("func %s: first out arg must be proto.Message", bcmd.FuncKey(fn))
+	}
+	rsp := reflect.New(t.Out(0).Elem()).Interface().(proto.Message)
+	err = c.c.Do(route.withCtx(ctx), route.srv, route.path, route.method, req, rsp)
+	if err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+var protoType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+// Invoke 类型化的按函数引用调用, 请求与响应类型由 fn 推导
+// 如: rsp, err := http.Invoke(ctx, client, svc.GetUser, req)
+func Invoke[Req any, Rsp any, PReq interface {
+	*Req
+	proto.Message
+}, PRsp interface {
+	*Rsp
+	proto.Message
+}](ctx context.Context, c *CmdClient, fn func(ctx context.Context, req PReq) (PRsp, error), req PReq) (PRsp, error) {
+	route, err := c.getRoute(fn)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return nil, err
+	}
+	return Call[Req, Rsp, PReq, PRsp](route.withCtx(ctx), c.c, route.srv, route.path, route.method, req)
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"context"
+	"github.com/oldbai555/micro/bgin/gate"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type echoSrv struct{}
+
+func (s *echoSrv) Upper(_ context.Context, req *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	return wrapperspb.String(strings.ToUpper(req.GetValue())), nil
+}
+
+func (s *echoSrv) Lower(_ context.Context, req *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	return wrapperspb.String(strings.ToLower(req.GetValue())), nil
+}
+
+var echoServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.EchoService",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Upper"},
+		{MethodName: "Lower"},
+	},
+}
+
+// newEchoServer 按路径分发到 echoSrv, 以网关的 json 信封响应
+func newEchoServer(t *testing.T) *httptest.Server {
+	svc := &echoSrv{}
+	return httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		req := &wrapperspb.StringValue{}
+		buf, _ := io.ReadAll(r.Body)
+		if err := JsonCodec.Unmarshal(buf, req); err != nil {
+			t.Errorf("unmarshal err:%v", err)
+		}
+		var rsp *wrapperspb.StringValue
+		switch r.URL.Path {
+		case "/test.EchoService/Upper":
+			rsp, _ = svc.Upper(r.Context(), req)
+		case "/test.EchoService/Lower":
+			rsp, _ = svc.Lower(r.Context(), req)
+		default:
+			writeEnvelope(w, nethttp.StatusNotFound, 404, "")
+			return
+		}
+		data, _ := JsonCodec.Marshal(rsp)
+		writeEnvelope(w, nethttp.StatusOK, 0, string(data))
+	}))
+}
+
+func TestCmdClientFromServiceDesc(t *testing.T) {
+	srv := newEchoServer(t)
+	defer srv.Close()
+
+	svc := &echoSrv{}
+	cmdList := gate.CmdListFromServiceDesc(&echoServiceDesc, svc)
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	c, err := NewCmdClient("echo", cmdList, WithCodec(JsonCodec), WithSelector(NewStaticSelector(addr)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 方法值
+	rsp, err := Invoke(context.Background(), c, svc.Upper, wrapperspb.String("Hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.GetValue() != "HELLO" {
+		t.Fatalf("Upper got %q", rsp.GetValue())
+	}
+
+	// 方法表达式
+	out, err := c.Invoke(context.Background(), (*echoSrv).Lower, wrapperspb.String("Hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.(*wrapperspb.StringValue).GetValue() != "hello" {
+		t.Fatalf("Lower got %q", out.(*wrapperspb.StringValue).GetValue())
+	}
+
+	// 方法全名
+	lower := &wrapperspb.StringValue{}
+	err = c.CallMethod(context.Background(), "/test.EchoService/Lower", wrapperspb.String("WORLD"), lower)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lower.GetValue() != "world" {
+		t.Fatalf("CallMethod got %q", lower.GetValue())
+	}
+}
+
+func TestCmdClientAmbiguousName(t *testing.T) {
+	svc := &echoSrv{}
+	other := echoServiceDesc
+	other.ServiceName = "test.OtherService"
+	cmdList := append(gate.CmdListFromServiceDesc(&echoServiceDesc, svc), gate.CmdListFromServiceDesc(&other, svc)...)
+	c, err := NewCmdClient("echo", cmdList, WithCodec(JsonCodec), WithSelector(NewStaticSelector("127.0.0.1:1")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Invoke(context.Background(), c, svc.Upper, wrapperspb.String("Hello"))
+	if err == nil || !strings.Contains(err.Error(), "CallMethod") {
+		t.Fatalf("got err:%v, want ambiguous err", err)
+	}
+	if _, ok := c.routeMap["/test.OtherService/Upper"]; !ok {
+		t.Fatal("cmd should be keyed by full method")
+	}
+	if c.routeMap["/test.EchoService/Upper"].method != nethttp.MethodPost {
+		t.Fatalf("unexpected method %s", c.routeMap["/test.EchoService/Upper"].method)
+	}
+}
@@ -0,0 +1,336 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/oldbai555/lbtool/log"
+	"github.com/oldbai555/lbtool/pkg/json"
+	"github.com/oldbai555/lbtool/pkg/lberr"
+	"github.com/oldbai555/lbtool/utils"
+	"github.com/oldbai555/micro/bconst"
+	"github.com/oldbai555/micro/bprometheus"
+	"github.com/oldbai555/micro/btrace"
+	"github.com/oldbai555/micro/uctx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"math/rand"
+	"net"
+	nethttp "net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout    = 10 * time.Second
+	defaultBackoff    = 100 * time.Millisecond
+	defaultMaxBackoff = 2 * time.Second
+)
+
+// Client 服务间通过网关协议调用, 响应信封中 errcode 非 0 即为错误
+type Client struct {
+	httpClient *nethttp.Client
+	selector   Selector
+	codec      Codec
+	scheme     string
+	timeout    time.Duration
+	retry      int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	header     nethttp.Header
+}
+
+type Option func(*Client)
+
+func WithHttpClient(c *nethttp.Client) Option {
+	return func(client *Client) {
+		client.httpClient = c
+	}
+}
+
+// WithSelector 节点选择, 默认通过 etcd 服务发现随机选择
+func WithSelector(s Selector) Option {
+	return func(client *Client) {
+		client.selector = s
+	}
+}
+
+// WithCodec 请求体的编码, 默认 ProtoCodec
+func WithCodec(c Codec) Option {
+	return func(client *Client) {
+		client.codec = c
+	}
+}
+
+// WithTimeout 单次尝试的超时, ctx 的截止时间更早时以 ctx 为准
+func WithTimeout(timeout time.Duration) Option {
+	return func(client *Client) {
+		client.timeout = timeout
+	}
+}
+
+// WithRetry 失败后重试, 退避时间指数增长
+// 默认只重试连接失败, 请求未发出, 不会重复执行
+// 调用经 MarkIdempotent 标记为幂等时, 超时, 429 与 502/503/504 同样重试
+// 重试时携带同一个 Idempotency-Key, 声明了幂等的命令不会重复执行
+func WithRetry(retry int, backoff, maxBackoff time.Duration) Option {
+	return func(client *Client) {
+		client.retry = retry
+		client.backoff = backoff
+		client.maxBackoff = maxBackoff
+	}
+}
+
+func WithScheme(scheme string) Option {
+	return func(client *Client) {
+		client.scheme = scheme
+	}
+}
+
+// WithHeader 每次请求附带的请求头
+func WithHeader(key, val string) Option {
+	return func(client *Client) {
+		client.header.Set(key, val)
+	}
+}
+
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		httpClient: &nethttp.Client{},
+		codec:      ProtoCodec,
+		scheme:     "http",
+		timeout:    defaultTimeout,
+		backoff:    defaultBackoff,
+		maxBackoff: defaultMaxBackoff,
+		header:     nethttp.Header{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.selector == nil {
+		c.selector = NewDispatchSelector(nil, PolicyRandom)
+	}
+	return c
+}
+
+type idempotentKey struct{}
+
+// MarkIdempotent 标记调用是幂等的, 失败后可以重试超时与 5xx, 请求可能已被服务端处理
+// 如: err := client.Do(http.MarkIdempotent(ctx), "user", "/user/get", http.MethodPost, req, rsp)
+func MarkIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(ctx context.Context) bool {
+	ok, _ := ctx.Value(idempotentKey{}).(bool)
+	return ok
+}
+
+// retryableErr 可以重试的错误, connect 为 true 时请求未发出, 非幂等的调用也可以重试
+type retryableErr struct {
+	err     error
+	connect bool
+}
+
+func (e *retryableErr) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableErr) Unwrap() error {
+	return e.err
+}
+
+// Do 调用 srv 的 path, 响应写入 out
+func (c *Client) Do(ctx context.Context, srv, path, method string, req, out proto.Message) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	body, err := c.codec.Marshal(req)
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return err
+	}
+
+	ctx, span := btrace.Start(ctx, srv+path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(method),
+			semconv.NetPeerNameKey.String(srv),
+			semconv.HTTPRouteKey.String(path),
+		),
+	)
+	header := c.newHeader(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+	if c.retry > 0 && header.Get(bconst.HeaderIdempotencyKey) == "" {
+		header.Set(bconst.HeaderIdempotencyKey, utils.GenRandomStr())
+	}
+
+	end := bprometheus.HttpClientMetrics.Begin(srv + path)
+	var rspSize int
+	defer func() {
+		end(errCode(err), len(body), rspSize)
+		btrace.End(span, err)
+	}()
+
+	idempotent := isIdempotent(ctx)
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		rspSize, err = c.doOnce(ctx, srv, path, method, header, body, out)
+		var rErr *retryableErr
+		if errors.As(err, &rErr) {
+			err = rErr.err
+		}
+		if rErr == nil || !(rErr.connect || idempotent) || attempt >= c.retry {
+			break
+		}
+		log.Warnf("http: retry %s%s attempt:%d err:%v", srv, path, attempt+1, err)
+
+		// 加入随机抖动, 避免同时重试
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+		if c.maxBackoff > 0 && backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+	if err != nil {
+		log.Errorf("err:%v", err)
+		return err
+	}
+	return nil
+}
+
+// newHeader 透传 uctx 中的 trace id, sid 等
+func (c *Client) newHeader(ctx context.Context) nethttp.Header {
+	header := c.header.Clone()
+	header.Set(bconst.ProtocolType, c.codec.ProtocolType())
+	header.Set(bconst.HttpHeaderContentType, contentType(c.codec))
+
+	nCtx, err := uctx.ToUCtx(ctx)
+	if err != nil {
+		return header
+	}
+	setIf := func(key, val string) {
+		if val != "" {
+			header.Set(key, val)
+		}
+	}
+	setIf(bconst.GinHeaderTraceId, nCtx.TraceId())
+	setIf(bconst.GinHeaderDeviceId, nCtx.DeviceId())
+	setIf(bconst.GinHeaderSid, nCtx.Sid())
+	setIf(bconst.GinHeaderAuthType, nCtx.AuthType())
+	setIf(bconst.GinHeaderLocale, nCtx.Locale())
+	return header
+}
+
+func contentType(codec Codec) string {
+	if codec.ProtocolType() == bconst.PROTO_TYPE_API_JSON {
+		return bconst.HttpHeaderContentTypeByJson
+	}
+	return "application/x-protobuf"
+}
+
+func (c *Client) target(addr, path string) (string, error) {
+	if !strings.Contains(addr, "://") {
+		addr = c.scheme + "://" + addr
+	}
+	return url.JoinPath(addr, path)
+}
+
+// errCode 监控中的 code 标签, 非 lberr 的错误为 -1
+func errCode(err error) string {
+	if err == nil {
+		return bprometheus.CodeOK
+	}
+	return strconv.Itoa(int(lberr.GetErrCode(err)))
+}
+
+// doOnce 返回响应体的大小, 未收到响应时为 -1
+func (c *Client) doOnce(ctx context.Context, srv, path, method string, header nethttp.Header, body []byte, out proto.Message) (int, error) {
+	addr, err := c.selector.Select(ctx, srv)
+	if err != nil {
+		return -1, err
+	}
+	target, err := c.target(addr, path)
+	if err != nil {
+		return -1, err
+	}
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	httpReq, err := nethttp.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	httpReq.Header = header.Clone()
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return -1, &retryableErr{err: err, connect: true}
+		}
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) {
+			return -1, &retryableErr{err: err}
+		}
+		return -1, err
+	}
+	defer resp.Body.Close()
+
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return -1, &retryableErr{err: err}
+	}
+	return len(buf), decodeResp(resp, buf, out)
+}
+
+// decodeResp json 信封中 errcode 非 0 即为错误, 包括 KSystemError 等负数错误码
+func decodeResp(resp *nethttp.Response, buf []byte, out proto.Message) error {
+	protocolType := resp.Header.Get(bconst.ProtocolType)
+	if protocolType == bconst.PROTO_TYPE_PROTO3 && resp.StatusCode == nethttp.StatusOK {
+		return proto.Unmarshal(buf, out)
+	}
+
+	var respBody Resp
+	err := json.Unmarshal(buf, &respBody)
+	if err != nil || protocolType != bconst.PROTO_TYPE_API_JSON {
+		err = fmt.Errorf("unexpected response status:%d protocol:%q", resp.StatusCode, protocolType)
+		if isRetryableStatus(resp.StatusCode) {
+			return &retryableErr{err: err}
+		}
+		return err
+	}
+	if respBody.ErrCode != 0 {
+		err = lberr.NewErr(respBody.ErrCode, respBody.ErrMsg)
+		if isRetryableStatus(resp.StatusCode) {
+			return &retryableErr{err: err}
+		}
+		return err
+	}
+	return JsonCodec.Unmarshal([]byte(respBody.Data), out)
+}
+
+func isRetryableStatus(status int) bool {
+	switch status {
+	case nethttp.StatusTooManyRequests, nethttp.StatusBadGateway,
+		nethttp.StatusServiceUnavailable, nethttp.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
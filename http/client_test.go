@@ -0,0 +1,240 @@
+package http
+
+import (
+	"context"
+	"github.com/oldbai555/lbtool/pkg/json"
+	"github.com/oldbai555/lbtool/pkg/lberr"
+	"github.com/oldbai555/micro/bconst"
+	"github.com/oldbai555/micro/uctx"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeEnvelope 以网关的 json 信封响应
+func writeEnvelope(w nethttp.ResponseWriter, status int, errCode int32, data string) {
+	buf, _ := json.Marshal(&Resp{Data: data, ErrCode: errCode})
+	w.Header().Set(bconst.ProtocolType, bconst.PROTO_TYPE_API_JSON)
+	w.WriteHeader(status)
+	_, _ = w.Write(buf)
+}
+
+func newTestClient(srv *httptest.Server, opts ...Option) *Client {
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	return NewClient(append([]Option{WithCodec(JsonCodec), WithSelector(NewStaticSelector(addr))}, opts...)...)
+}
+
+// closedAddr 一个没有监听的地址, 连接会被拒绝
+func closedAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+	return addr
+}
+
+func TestClientDo(t *testing.T) {
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path != "/user/get" || r.Method != nethttp.MethodPost {
+			writeEnvelope(w, nethttp.StatusNotFound, 404, "")
+			return
+		}
+		if r.Header.Get(bconst.ProtocolType) != bconst.PROTO_TYPE_API_JSON {
+			writeEnvelope(w, nethttp.StatusBadRequest, 400, "")
+			return
+		}
+		writeEnvelope(w, nethttp.StatusOK, 0, `"pong"`)
+	}))
+	defer srv.Close()
+
+	rsp := &wrapperspb.StringValue{}
+	err := newTestClient(srv).Do(context.Background(), "user", "/user/get", nethttp.MethodPost, wrapperspb.String("ping"), rsp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.GetValue() != "pong" {
+		t.Fatalf("got %q", rsp.GetValue())
+	}
+}
+
+func TestClientErrCode(t *testing.T) {
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		writeEnvelope(w, nethttp.StatusOK, 1001, "")
+	}))
+	defer srv.Close()
+
+	err := newTestClient(srv).Do(context.Background(), "user", "/user/get", nethttp.MethodPost, wrapperspb.String("ping"), &wrapperspb.StringValue{})
+	if lberr.GetErrCode(err) != 1001 {
+		t.Fatalf("got err:%v, want errcode 1001", err)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	var count int32
+	var lock sync.Mutex
+	keySet := map[string]bool{}
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		lock.Lock()
+		keySet[r.Header.Get(bconst.HeaderIdempotencyKey)] = true
+		lock.Unlock()
+		atomic.AddInt32(&count, 1)
+		writeEnvelope(w, nethttp.StatusServiceUnavailable, 503, "")
+	}))
+	defer srv.Close()
+	c := newTestClient(srv, WithRetry(2, time.Millisecond, time.Millisecond))
+
+	// 未标记幂等时不重试 5xx, 请求可能已被处理
+	err := c.Do(context.Background(), "user", "/user/update", nethttp.MethodPost, wrapperspb.String("ping"), &wrapperspb.StringValue{})
+	if lberr.GetErrCode(err) != 503 {
+		t.Fatalf("got err:%v, want errcode 503", err)
+	}
+	if n := atomic.LoadInt32(&count); n != 1 {
+		t.Fatalf("non idempotent call attempted %d times, want 1", n)
+	}
+
+	atomic.StoreInt32(&count, 0)
+	lock.Lock()
+	keySet = map[string]bool{}
+	lock.Unlock()
+	err = c.Do(MarkIdempotent(context.Background()), "user", "/user/get", nethttp.MethodPost, wrapperspb.String("ping"), &wrapperspb.StringValue{})
+	if lberr.GetErrCode(err) != 503 {
+		t.Fatalf("got err:%v, want errcode 503", err)
+	}
+	if n := atomic.LoadInt32(&count); n != 3 {
+		t.Fatalf("idempotent call attempted %d times, want 3", n)
+	}
+	if len(keySet) != 1 || keySet[""] {
+		t.Fatalf("retries should share one idempotency key: %v", keySet)
+	}
+}
+
+func TestClientRetryConnect(t *testing.T) {
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		writeEnvelope(w, nethttp.StatusOK, 0, `"pong"`)
+	}))
+	defer srv.Close()
+
+	// 第一次选到不可用的节点, 连接失败的请求未发出, 非幂等的调用同样重试
+	addrList := []string{closedAddr(t), strings.TrimPrefix(srv.URL, "http://")}
+	var i int32
+	selector := SelectorFunc(func(ctx context.Context, srv string) (string, error) {
+		return addrList[int(atomic.AddInt32(&i, 1)-1)%len(addrList)], nil
+	})
+	c := NewClient(WithCodec(JsonCodec), WithSelector(selector), WithRetry(1, time.Millisecond, time.Millisecond))
+
+	rsp := &wrapperspb.StringValue{}
+	err := c.Do(context.Background(), "user", "/user/update", nethttp.MethodPost, wrapperspb.String("ping"), rsp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.GetValue() != "pong" {
+		t.Fatalf("got %q", rsp.GetValue())
+	}
+}
+
+func TestClientBackoff(t *testing.T) {
+	var timeList []time.Time
+	var lock sync.Mutex
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		lock.Lock()
+		timeList = append(timeList, time.Now())
+		lock.Unlock()
+		writeEnvelope(w, nethttp.StatusBadGateway, 502, "")
+	}))
+	defer srv.Close()
+
+	backoff := 40 * time.Millisecond
+	c := newTestClient(srv, WithRetry(3, backoff, 60*time.Millisecond))
+	_ = c.Do(MarkIdempotent(context.Background()), "user", "/user/get", nethttp.MethodPost, wrapperspb.String("ping"), &wrapperspb.StringValue{})
+
+	if len(timeList) != 4 {
+		t.Fatalf("attempted %d times, want 4", len(timeList))
+	}
+	// 每次等待在 [backoff/2, backoff] 之间, backoff 翻倍且不超过 maxBackoff
+	wantMin := []time.Duration{20 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond}
+	for i, min := range wantMin {
+		if wait := timeList[i+1].Sub(timeList[i]); wait < min {
+			t.Fatalf("wait %d is %v, want >= %v", i, wait, min)
+		}
+	}
+}
+
+func TestClientRetryCtxDone(t *testing.T) {
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		writeEnvelope(w, nethttp.StatusServiceUnavailable, 503, "")
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(MarkIdempotent(context.Background()), 50*time.Millisecond)
+	defer cancel()
+	c := newTestClient(srv, WithRetry(10, time.Second, time.Second))
+	err := c.Do(ctx, "user", "/user/get", nethttp.MethodPost, wrapperspb.String("ping"), &wrapperspb.StringValue{})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("got err:%v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestClientUCtxHeader(t *testing.T) {
+	var header nethttp.Header
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		header = r.Header.Clone()
+		writeEnvelope(w, nethttp.StatusOK, 0, `"pong"`)
+	}))
+	defer srv.Close()
+
+	nCtx := uctx.NewBaseUCtx()
+	nCtx.SetTraceId("trace-1")
+	nCtx.SetDeviceId("device-1")
+	nCtx.SetSid("sid-1")
+	nCtx.SetAuthType("user")
+	nCtx.SetLocale("zh-CN")
+
+	c := newTestClient(srv, WithHeader(bconst.GinHeaderCaller, "svc/1.0"))
+	err := c.Do(nCtx, "user", "/user/get", nethttp.MethodPost, wrapperspb.String("ping"), &wrapperspb.StringValue{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		bconst.GinHeaderTraceId:  "trace-1",
+		bconst.GinHeaderDeviceId: "device-1",
+		bconst.GinHeaderSid:      "sid-1",
+		bconst.GinHeaderAuthType: "user",
+		bconst.GinHeaderLocale:   "zh-CN",
+		bconst.GinHeaderCaller:   "svc/1.0",
+		bconst.ProtocolType:      bconst.PROTO_TYPE_API_JSON,
+	} {
+		if got := header.Get(key); got != want {
+			t.Errorf("header %s: got %q, want %q", key, got, want)
+		}
+	}
+	if header.Get(bconst.HeaderIdempotencyKey) != "" {
+		t.Errorf("idempotency key should only be set when retry is enabled")
+	}
+}
+
+func TestClientProto(t *testing.T) {
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Header().Set(bconst.ProtocolType, bconst.PROTO_TYPE_PROTO3)
+		buf, _ := ProtoCodec.Marshal(wrapperspb.String("pong"))
+		_, _ = w.Write(buf)
+	}))
+	defer srv.Close()
+
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	rsp := &wrapperspb.StringValue{}
+	err := NewClient(WithSelector(NewStaticSelector(addr))).Do(context.Background(), "user", "/user/get", nethttp.MethodPost, wrapperspb.String("ping"), rsp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.GetValue() != "pong" {
+		t.Fatalf("got %q", rsp.GetValue())
+	}
+}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"github.com/oldbai555/lbtool/pkg/jsonpb"
+	"github.com/oldbai555/lbtool/pkg/lberr"
+	"github.com/oldbai555/micro/bconst"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec 请求体的编码方式, 与 X-LB-PROTO-TYPE 对应
+type Codec interface {
+	ProtocolType() string
+	Marshal(m proto.Message) ([]byte, error)
+	Unmarshal(buf []byte, m proto.Message) error
+}
+
+var (
+	JsonCodec  Codec = jsonCodec{}
+	ProtoCodec Codec = protoCodec{}
+)
+
+// CodecByProtocol 根据 X-LB-PROTO-TYPE 的值选择 Codec
+func CodecByProtocol(protocolType string) (Codec, error) {
+	switch protocolType {
+	case bconst.PROTO_TYPE_API_JSON:
+		return JsonCodec, nil
+	case bconst.PROTO_TYPE_PROTO3:
+		return ProtoCodec, nil
+	}
+	return nil, lberr.NewInvalidArg("not found protocol type , val is %s", protocolType)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ProtocolType() string {
+	return bconst.PROTO_TYPE_API_JSON
+}
+
+func (jsonCodec) Marshal(m proto.Message) ([]byte, error) {
+	val, err := jsonpb.MarshalToString(m)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(val), nil
+}
+
+func (jsonCodec) Unmarshal(buf []byte, m proto.Message) error {
+	return jsonpb.Unmarshal(buf, m)
+}
+
+type protoCodec struct{}
+
+func (protoCodec) ProtocolType() string {
+	return bconst.PROTO_TYPE_PROTO3
+}
+
+func (protoCodec) Marshal(m proto.Message) ([]byte, error) {
+	return proto.Marshal(m)
+}
+
+func (protoCodec) Unmarshal(buf []byte, m proto.Message) error {
+	return proto.Unmarshal(buf, m)
+}
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"github.com/oldbai555/micro/bconst"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"testing"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	in, err := structpb.NewStruct(map[string]interface{}{"name": "lb", "age": 18.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, codec := range []Codec{JsonCodec, ProtoCodec} {
+		buf, err := codec.Marshal(in)
+		if err != nil {
+			t.Fatalf("%s: marshal err:%v", codec.ProtocolType(), err)
+		}
+		out := &structpb.Struct{}
+		err = codec.Unmarshal(buf, out)
+		if err != nil {
+			t.Fatalf("%s: unmarshal err:%v", codec.ProtocolType(), err)
+		}
+		if !proto.Equal(in, out) {
+			t.Fatalf("%s: got %v, want %v", codec.ProtocolType(), out, in)
+		}
+	}
+}
+
+func TestCodecByProtocol(t *testing.T) {
+	for protocolType, want := range map[string]Codec{
+		bconst.PROTO_TYPE_API_JSON: JsonCodec,
+		bconst.PROTO_TYPE_PROTO3:   ProtoCodec,
+	} {
+		codec, err := CodecByProtocol(protocolType)
+		if err != nil {
+			t.Fatalf("%s: err:%v", protocolType, err)
+		}
+		if codec != want {
+			t.Fatalf("%s: got %s", protocolType, codec.ProtocolType())
+		}
+	}
+	if _, err := CodecByProtocol("xml"); err == nil {
+		t.Fatal("expect err for unknown protocol")
+	}
+}
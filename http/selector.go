@@ -0,0 +1,110 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"github.com/oldbai555/lbtool/pkg/dispatch"
+	"github.com/oldbai555/micro/brpc/dispatchimpl"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Selector 为一次请求选择服务节点, 返回 host:port
+type Selector interface {
+	Select(ctx context.Context, srv string) (string, error)
+}
+
+type SelectorFunc func(ctx context.Context, srv string) (string, error)
+
+func (f SelectorFunc) Select(ctx context.Context, srv string) (string, error) {
+	return f(ctx, srv)
+}
+
+type Policy int
+
+const (
+	PolicyRandom     Policy = iota // 随机, 与 dispatch.Route 一致
+	PolicyRoundRobin               // 按服务轮询
+)
+
+// nodeAddr 注册时端口写在 Extra 中, 兼容只填了 Port 的节点
+func nodeAddr(node *dispatch.Node) string {
+	port := node.Extra
+	if port == "" {
+		port = strconv.Itoa(node.Port)
+	}
+	return net.JoinHostPort(node.Host, port)
+}
+
+// dispatchSelector 通过 etcd 服务发现选择节点
+type dispatchSelector struct {
+	d      dispatch.IDispatch
+	policy Policy
+
+	lock    sync.Mutex
+	counter map[string]*uint64
+}
+
+// NewDispatchSelector d 为 nil 时使用 dispatchimpl.New()
+func NewDispatchSelector(d dispatch.IDispatch, policy Policy) Selector {
+	return &dispatchSelector{d: d, policy: policy, counter: map[string]*uint64{}}
+}
+
+func (s *dispatchSelector) Select(ctx context.Context, srv string) (string, error) {
+	d := s.d
+	if d == nil {
+		var err error
+		d, err = dispatchimpl.New()
+		if err != nil {
+			return "", err
+		}
+	}
+	service, err := d.Discover(ctx, srv)
+	if err != nil {
+		return "", err
+	}
+	var nodeList []*dispatch.Node
+	for _, node := range service.Nodes {
+		if node.Available() {
+			nodeList = append(nodeList, node)
+		}
+	}
+	if len(nodeList) == 0 {
+		return "", dispatch.ErrNodeNotFound
+	}
+
+	var i int
+	switch s.policy {
+	case PolicyRoundRobin:
+		i = int(atomic.AddUint64(s.getCounter(srv), 1) % uint64(len(nodeList)))
+	default:
+		i = rand.Intn(len(nodeList))
+	}
+	return nodeAddr(nodeList[i]), nil
+}
+
+func (s *dispatchSelector) getCounter(srv string) *uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	c, ok := s.counter[srv]
+	if !ok {
+		c = new(uint64)
+		s.counter[srv] = c
+	}
+	return c
+}
+
+// NewStaticSelector 固定的节点列表, 按轮询选择, 常用于测试或未接入服务发现的服务
+func NewStaticSelector(addrList ...string) Selector {
+	var counter uint64
+	return SelectorFunc(func(ctx context.Context, srv string) (string, error) {
+		if len(addrList) == 0 {
+			return "", fmt.Errorf("%w: %s", dispatch.ErrNodeNotFound, srv)
+		}
+		i := atomic.AddUint64(&counter, 1) % uint64(len(addrList))
+		return addrList[i], nil
+	})
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"github.com/oldbai555/lbtool/pkg/dispatch"
+	"testing"
+)
+
+// fakeDispatch 只实现 Discover
+type fakeDispatch struct {
+	dispatch.IDispatch
+	nodeList []*dispatch.Node
+}
+
+func (d *fakeDispatch) Discover(_ context.Context, srvName string) (*dispatch.Service, error) {
+	return &dispatch.Service{SrvName: srvName, Nodes: d.nodeList}, nil
+}
+
+func TestStaticSelector(t *testing.T) {
+	s := NewStaticSelector("a:1", "b:2")
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		addr, err := s.Select(context.Background(), "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[addr]++
+	}
+	if seen["a:1"] != 2 || seen["b:2"] != 2 {
+		t.Fatalf("not round robin: %v", seen)
+	}
+
+	_, err := NewStaticSelector().Select(context.Background(), "user")
+	if !errors.Is(err, dispatch.ErrNodeNotFound) {
+		t.Fatalf("got err:%v, want ErrNodeNotFound", err)
+	}
+}
+
+func TestDispatchSelector(t *testing.T) {
+	dead := dispatch.NewNode("10.0.0.3", 8080)
+	dead.Status = dispatch.NodeStateDead
+	withExtra := dispatch.NewNode("10.0.0.2", 8080)
+	withExtra.Extra = "9090"
+	d := &fakeDispatch{nodeList: []*dispatch.Node{dispatch.NewNode("10.0.0.1", 8080), withExtra, dead}}
+
+	s := NewDispatchSelector(d, PolicyRoundRobin)
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		addr, err := s.Select(context.Background(), "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[addr]++
+	}
+	if seen["10.0.0.1:8080"] != 2 || seen["10.0.0.2:9090"] != 2 || len(seen) != 2 {
+		t.Fatalf("unexpected selection: %v", seen)
+	}
+
+	s = NewDispatchSelector(&fakeDispatch{nodeList: []*dispatch.Node{dead}}, PolicyRandom)
+	_, err := s.Select(context.Background(), "user")
+	if !errors.Is(err, dispatch.ErrNodeNotFound) {
+		t.Fatalf("got err:%v, want ErrNodeNotFound", err)
+	}
+}
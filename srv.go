@@ -28,6 +28,8 @@ type GrpcWithGateSrv struct {
 	checkAuthFunc gate.CheckAuthFunc
 	cmdList       []*bcmd.Cmd
 	interceptors  []grpc.UnaryServerInterceptor
+	gateOpts      []gate.Option
+	metricsOpts   []bprometheus.Option
 
 	useDefaultSrvReg bool
 }
@@ -66,6 +68,12 @@ func WithUnaryServerInterceptors(list ...grpc.UnaryServerInterceptor) Option {
 	}
 }
 
+func WithGateOptions(list ...gate.Option) Option {
+	return func(gateSrv *GrpcWithGateSrv) {
+		gateSrv.gateOpts = append(gateSrv.gateOpts, list...)
+	}
+}
+
 func WithUseDefaultSrvReg() Option {
 	return func(gateSrv *GrpcWithGateSrv) {
 		gateSrv.useDefaultSrvReg = true
@@ -84,9 +92,16 @@ func WithPrometheusPort(prometheusPort uint32) Option {
 	}
 }
 
+// WithPrometheusOptions 监控端口的 Registry, 鉴权与 build_info 等配置
+func WithPrometheusOptions(list ...bprometheus.Option) Option {
+	return func(gateSrv *GrpcWithGateSrv) {
+		gateSrv.metricsOpts = append(gateSrv.metricsOpts, list...)
+	}
+}
+
 func (s *GrpcWithGateSrv) Start(ctx context.Context) error {
 	grpcSrv := brpc.NewSvr(s.name, s.port, s.rf, s.interceptors...)
-	gateSrv := gate.NewSvr(s.name, s.genGatePort(), s.cmdList, s.checkAuthFunc)
+	gateSrv := gate.NewSvr(s.name, s.genGatePort(), s.cmdList, s.checkAuthFunc, s.gateOpts...)
 	defer func() {
 		grpcSrv.Stop()
 		gateSrv.Stop()
@@ -113,7 +128,7 @@ func (s *GrpcWithGateSrv) Start(ctx context.Context) error {
 
 	// 启动监控
 	routine.GoV2(func() error {
-		err := bprometheus.StartPrometheusMonitor("", s.genPrometheusPort())
+		err := bprometheus.StartPrometheusMonitor("", s.genPrometheusPort(), s.metricsOpts...)
 		if err != nil {
 			log.Errorf("err:%v", err)
 			return err
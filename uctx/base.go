@@ -2,66 +2,131 @@ package uctx
 
 import "context"
 
+// BaseUCtx 身份字段以 context value 的形式保存在 Context 中
+// 由其派生的 context (WithTimeout, WithCancel 等) 同样携带这些字段
 type BaseUCtx struct {
 	context.Context
-	sid          string
-	deviceId     string
-	traceId      string
-	authType     string
-	protocolType string
-	extInfo      interface{}
 }
 
 func NewBaseUCtx() *BaseUCtx {
-	return &BaseUCtx{
-		Context: context.Background(),
+	return New(context.Background())
+}
+
+// New 以 ctx 为父 context, 截止时间与取消信号以 ctx 为准, 并继承 ctx 中已有的身份字段
+func New(ctx context.Context) *BaseUCtx {
+	if ctx == nil {
+		ctx = context.Background()
 	}
+	return &BaseUCtx{Context: ctx}
+}
+
+func (U *BaseUCtx) vals() *values {
+	return valuesFrom(U.Context)
+}
+
+// set 兼容旧的 SetXxx, 以新的 values 派生 Context, 已派生出去的 context 不受影响
+func (U *BaseUCtx) set(f func(v *values)) {
+	v := U.vals().clone()
+	f(v)
+	U.Context = context.WithValue(U.Context, valuesKey{}, v)
 }
 
 func (U *BaseUCtx) ProtocolType() string {
-	return U.protocolType
+	return U.vals().protocolType
 }
 
 func (U *BaseUCtx) SetProtocolType(protoType string) {
-	U.protocolType = protoType
+	U.set(func(v *values) { v.protocolType = protoType })
 }
 
 func (U *BaseUCtx) ExtInfo() interface{} {
-	return U.extInfo
+	return U.vals().extInfo
 }
 
 func (U *BaseUCtx) SetExtInfo(i interface{}) {
-	U.extInfo = i
+	U.set(func(v *values) { v.setExtInfo(i) })
 }
 
 func (U *BaseUCtx) AuthType() string {
-	return U.authType
+	return U.vals().authType
 }
 
 func (U *BaseUCtx) SetAuthType(authType string) {
-	U.authType = authType
+	U.set(func(v *values) { v.authType = authType })
 }
 
 func (U *BaseUCtx) Sid() string {
-	return U.sid
+	return U.vals().sid
 }
 
 func (U *BaseUCtx) SetSid(sid string) {
-	U.sid = sid
+	U.set(func(v *values) { v.sid = sid })
 }
 
 func (U *BaseUCtx) DeviceId() string {
-	return U.deviceId
+	return U.vals().deviceId
 }
 
 func (U *BaseUCtx) SetDeviceId(deviceId string) {
-	U.deviceId = deviceId
+	U.set(func(v *values) { v.deviceId = deviceId })
 }
 
 func (U *BaseUCtx) TraceId() string {
-	return U.traceId
+	return U.vals().traceId
 }
 
 func (U *BaseUCtx) SetTraceId(traceId string) {
-	U.traceId = traceId
+	U.set(func(v *values) { v.traceId = traceId })
+}
+
+func (U *BaseUCtx) UserId() uint64 {
+	return U.vals().userId
+}
+
+func (U *BaseUCtx) SetUserId(userId uint64) {
+	U.set(func(v *values) { v.userId = userId })
+}
+
+func (U *BaseUCtx) CorpId() uint32 {
+	return U.vals().corpId
+}
+
+func (U *BaseUCtx) SetCorpId(corpId uint32) {
+	U.set(func(v *values) { v.corpId = corpId })
+}
+
+func (U *BaseUCtx) AppId() string {
+	return U.vals().appId
+}
+
+func (U *BaseUCtx) SetAppId(appId string) {
+	U.set(func(v *values) { v.appId = appId })
+}
+
+func (U *BaseUCtx) Roles() []string {
+	return U.vals().roles
+}
+
+func (U *BaseUCtx) SetRoles(roles []string) {
+	U.set(func(v *values) { v.roles = roles })
+}
+
+func (U *BaseUCtx) Locale() string {
+	return U.vals().locale
+}
+
+func (U *BaseUCtx) SetLocale(locale string) {
+	U.set(func(v *values) { v.locale = locale })
+}
+
+func (U *BaseUCtx) ClientIp() string {
+	return U.vals().clientIp
+}
+
+func (U *BaseUCtx) SetClientIp(clientIp string) {
+	U.set(func(v *values) { v.clientIp = clientIp })
+}
+
+func (U *BaseUCtx) SetExtValue(key, val interface{}) {
+	U.set(func(v *values) { v.setExt(key, val) })
 }
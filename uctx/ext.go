@@ -0,0 +1,57 @@
+package uctx
+
+import "context"
+
+// Identity 调用方身份, 鉴权后写入 uctx 的类型化字段
+type Identity struct {
+	UserId uint64
+	CorpId uint32
+	AppId  string
+	Roles  []string
+}
+
+// IIdentity ExtInfo 实现该接口时, SetExtInfo 同时写入 UserId, CorpId, AppId 与 Roles
+type IIdentity interface {
+	GetIdentity() Identity
+}
+
+func (v *values) setExtInfo(extInfo interface{}) {
+	v.extInfo = extInfo
+	identity, ok := extInfo.(IIdentity)
+	if !ok {
+		return
+	}
+	id := identity.GetIdentity()
+	v.userId, v.corpId, v.appId, v.roles = id.UserId, id.CorpId, id.AppId, id.Roles
+}
+
+func (v *values) setExt(key, val interface{}) {
+	if v.extMap == nil {
+		v.extMap = map[interface{}]interface{}{}
+	}
+	v.extMap[key] = val
+}
+
+// extKey 每个类型对应一个 key, 不同包的同名类型互不影响
+type extKey[T any] struct{}
+
+// GetExt 取出 SetExt 写入的 T, 如 uctx.GetExt[*Member](nCtx)
+func GetExt[T any](ctx context.Context) (T, bool) {
+	val, ok := valuesFrom(ctx).extMap[extKey[T]{}]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	t, ok := val.(T)
+	return t, ok
+}
+
+// SetExt 以类型 T 为 key 写入扩展字段, 同一类型只保留最后一次写入
+func SetExt[T any](nCtx IUCtx, val T) {
+	nCtx.SetExtValue(extKey[T]{}, val)
+}
+
+// WithExt 返回携带扩展字段的派生 context
+func WithExt[T any](ctx context.Context, val T) context.Context {
+	return withValue(ctx, func(v *values) { v.setExt(extKey[T]{}, val) })
+}
@@ -19,14 +19,37 @@ type IUCtx interface {
 	SetExtInfo(interface{})
 	ProtocolType() string
 	SetProtocolType(authType string)
+
+	UserId() uint64
+	SetUserId(userId uint64)
+	CorpId() uint32 // 租户 id
+	SetCorpId(corpId uint32)
+	AppId() string
+	SetAppId(appId string)
+	Roles() []string
+	SetRoles(roles []string)
+	Locale() string
+	SetLocale(locale string)
+	ClientIp() string
+	SetClientIp(clientIp string)
+
+	// SetExtValue 供 SetExt 使用, 业务代码请使用 SetExt
+	SetExtValue(key, val interface{})
 }
 
+// ToUCtx 支持由 IUCtx 派生的 context, 如 context.WithTimeout(nCtx, d)
 func ToUCtx(ctx context.Context) (IUCtx, error) {
+	if ctx == nil {
+		return nil, convertErr
+	}
 	iuCtx, ok := ctx.(IUCtx)
-	if !ok {
+	if ok {
+		return iuCtx, nil
+	}
+	if !hasValues(ctx) {
 		return nil, convertErr
 	}
-	return iuCtx, nil
+	return New(ctx), nil
 }
 
 var convertErr = lberr.NewCustomErr("convert ctx failed")
@@ -0,0 +1,108 @@
+package uctx
+
+import "context"
+
+type valuesKey struct{}
+
+// values 请求的身份字段, 写入 context 后不再修改, 变更时复制一份
+type values struct {
+	sid          string
+	deviceId     string
+	traceId      string
+	authType     string
+	protocolType string
+	extInfo      interface{}
+
+	userId   uint64
+	corpId   uint32
+	appId    string
+	roles    []string
+	locale   string
+	clientIp string
+
+	extMap map[interface{}]interface{} // SetExt 写入, key 为 extKey[T]
+}
+
+var emptyValues = &values{}
+
+func (v *values) clone() *values {
+	c := *v
+	if v.extMap != nil {
+		c.extMap = make(map[interface{}]interface{}, len(v.extMap))
+		for k, val := range v.extMap {
+			c.extMap[k] = val
+		}
+	}
+	return &c
+}
+
+func valuesFrom(ctx context.Context) *values {
+	if ctx == nil {
+		return emptyValues
+	}
+	if v, ok := ctx.Value(valuesKey{}).(*values); ok {
+		return v
+	}
+	return emptyValues
+}
+
+func hasValues(ctx context.Context) bool {
+	_, ok := ctx.Value(valuesKey{}).(*values)
+	return ok
+}
+
+func withValue(ctx context.Context, f func(v *values)) context.Context {
+	v := valuesFrom(ctx).clone()
+	f(v)
+	return context.WithValue(ctx, valuesKey{}, v)
+}
+
+// WithSid 等返回携带该字段的派生 context, 不影响 ctx 本身
+
+func WithSid(ctx context.Context, sid string) context.Context {
+	return withValue(ctx, func(v *values) { v.sid = sid })
+}
+
+func WithDeviceId(ctx context.Context, deviceId string) context.Context {
+	return withValue(ctx, func(v *values) { v.deviceId = deviceId })
+}
+
+func WithTraceId(ctx context.Context, traceId string) context.Context {
+	return withValue(ctx, func(v *values) { v.traceId = traceId })
+}
+
+func WithAuthType(ctx context.Context, authType string) context.Context {
+	return withValue(ctx, func(v *values) { v.authType = authType })
+}
+
+func WithProtocolType(ctx context.Context, protocolType string) context.Context {
+	return withValue(ctx, func(v *values) { v.protocolType = protocolType })
+}
+
+func WithExtInfo(ctx context.Context, extInfo interface{}) context.Context {
+	return withValue(ctx, func(v *values) { v.setExtInfo(extInfo) })
+}
+
+func WithUserId(ctx context.Context, userId uint64) context.Context {
+	return withValue(ctx, func(v *values) { v.userId = userId })
+}
+
+func WithCorpId(ctx context.Context, corpId uint32) context.Context {
+	return withValue(ctx, func(v *values) { v.corpId = corpId })
+}
+
+func WithAppId(ctx context.Context, appId string) context.Context {
+	return withValue(ctx, func(v *values) { v.appId = appId })
+}
+
+func WithRoles(ctx context.Context, roles []string) context.Context {
+	return withValue(ctx, func(v *values) { v.roles = roles })
+}
+
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return withValue(ctx, func(v *values) { v.locale = locale })
+}
+
+func WithClientIp(ctx context.Context, clientIp string) context.Context {
+	return withValue(ctx, func(v *values) { v.clientIp = clientIp })
+}